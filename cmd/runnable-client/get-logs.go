@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/ambardhesi/runnable/internal/server"
 	"github.com/spf13/cobra"
 )
 
@@ -13,12 +15,32 @@ var (
 		RunE:  getJobLogs,
 		Args:  cobra.ExactArgs(1),
 	}
+	followLogs bool
+	logStream  string
+	timestamps bool
+	attempt    int
 )
 
+func init() {
+	cmdGetLogs.Flags().BoolVarP(&followLogs, "follow", "f", false, "Keep printing logs as the job writes them, until it finishes")
+	cmdGetLogs.Flags().StringVar(&logStream, "stream", "both", "Which output to print: stdout, stderr or both")
+	cmdGetLogs.Flags().BoolVarP(&timestamps, "timestamps", "t", false, "Prefix every line with the time it was written")
+	cmdGetLogs.Flags().IntVar(&attempt, "attempt", 0, "Only print the logs of this attempt at running the job, 1 being the first")
+}
+
 func getJobLogs(cobraCmd *cobra.Command, args []string) error {
 	jobID := args[0]
+	request := server.GetLogsRequest{
+		Stream:     logStream,
+		Timestamps: timestamps,
+		Attempt:    attempt,
+	}
+
+	if followLogs {
+		return makeClient().FollowLogs(jobID, request, os.Stdout)
+	}
 
-	logs, err := makeClient().GetLogs(jobID)
+	logs, err := makeClient().GetLogs(jobID, request)
 
 	if err != nil {
 		return err
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 
+	"github.com/ambardhesi/runnable/internal/server"
 	"github.com/spf13/cobra"
 )
 
@@ -25,5 +27,46 @@ func getJob(cobraCmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Job : %v\n", *job)
+
+	var response server.GetJobResponse
+	if json.Unmarshal([]byte(*job), &response) == nil && response.Reason != "" {
+		fmt.Printf("Ended : %v\n", describeEnd(response))
+	}
 	return nil
 }
+
+// Says why the job, or its last attempt, ended.
+func describeEnd(job server.GetJobResponse) string {
+	killedBy := "killed by " + job.Signal
+	if job.CoreDumped {
+		killedBy += " (core dumped)"
+	}
+
+	switch job.Reason {
+	case "Exited":
+		return fmt.Sprintf("exited with code %v", job.ExitCode)
+	case "Signaled":
+		return killedBy
+	case "OOMKilled":
+		return "killed for running out of memory"
+	case "Stopped", "TimedOut":
+		description := "stopped"
+		if job.Reason == "TimedOut" {
+			description = "stopped for running out of time"
+		}
+		if job.Signal != "" {
+			description += ", " + killedBy
+		} else {
+			description += fmt.Sprintf(", exited with code %v", job.ExitCode)
+		}
+		return description
+	case "StartFailed":
+		return "could not be started"
+	case "WaitFailed":
+		return "could not be waited for, so how it ended is unknown"
+	case "Lost":
+		return "lost track of when the server went away"
+	default:
+		return job.Reason
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ambardhesi/runnable/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdList = cobra.Command{
+		Use:   "list",
+		Short: "Lists your jobs, or another owner's, newest first.",
+		RunE:  listJobs,
+		Args:  cobra.NoArgs,
+	}
+	listRequest server.ListJobsRequest
+)
+
+func init() {
+	flags := cmdList.Flags()
+
+	flags.StringArrayVar(&listRequest.States, "state", nil, "Only list jobs in this state (eg Running). Can be repeated")
+	flags.StringVar(&listRequest.Since, "since", "", "Only list jobs started at or after this time (eg 2021-06-01T00:00:00Z)")
+	flags.StringVar(&listRequest.Until, "until", "", "Only list jobs started before this time (eg 2021-06-02T00:00:00Z)")
+	flags.StringVar(&listRequest.CommandPrefix, "command", "", "Only list jobs whose command starts with this")
+	flags.StringArrayVar(&listRequest.Labels, "label", nil, "Only list jobs with this label, in key=value format. Can be repeated")
+	flags.IntVar(&listRequest.Limit, "limit", 0, "Most jobs to list")
+	flags.StringVar(&listRequest.Cursor, "cursor", "", "Carry on from where a previous list stopped")
+	flags.StringVar(&listRequest.Order, "order", "desc", "asc to list the oldest jobs first, desc for the newest")
+	flags.StringVar(&listRequest.ScheduleID, "schedule", "", "Only list jobs started by this schedule")
+	flags.StringVar(&listRequest.WorkflowID, "workflow", "", "Only list jobs of this workflow")
+	flags.StringVar(&listRequest.OwnerID, "owner", "", "List this owner's jobs instead of your own, or * for every owner's. Needs a role that grants it")
+}
+
+func listJobs(cobraCmd *cobra.Command, args []string) error {
+	response, err := makeClient().ListJobs(listRequest)
+
+	if err != nil {
+		return err
+	}
+
+	// other owners' jobs are listed with who they belong to
+	owners := listRequest.OwnerID != ""
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if owners {
+		fmt.Fprint(w, "OWNER\t")
+	}
+	fmt.Fprintln(w, "ID\tSTATE\tEXIT CODE\tSTARTED\tCOMMAND")
+	for _, job := range response.Jobs {
+		started := "-"
+		if !job.StartTime.IsZero() {
+			started = job.StartTime.Local().Format(time.RFC3339)
+		}
+
+		command := quoteArgs(append([]string{job.Command}, job.Args...))
+		if job.Shell {
+			command = "sh -c " + command
+		}
+		if owners {
+			fmt.Fprintf(w, "%v\t", job.OwnerID)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", job.ID, job.State, job.ExitCode, started, command)
+	}
+	w.Flush()
+
+	if response.NextCursor != "" {
+		fmt.Printf("\nMore jobs with --cursor %v\n", response.NextCursor)
+	}
+
+	return nil
+}
+
+// joins argv for display, quoting anything that would not read back as a single argument
+func quoteArgs(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -8,11 +8,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-const (
-	// TODO Make this configurable (as a CLI flag)
-	serverAddress = "https://localhost:8080"
-)
-
 var (
 	rootCmd = cobra.Command{
 		Use:   "runnable-client",
@@ -21,6 +16,7 @@ var (
 	caCertFile     string
 	clientCertFile string
 	clientKeyFile  string
+	serverAddress  string
 )
 
 func init() {
@@ -29,12 +25,13 @@ func init() {
 	flags.StringVar(&caCertFile, "ca", "", "Ca Cert file path")
 	flags.StringVar(&clientCertFile, "cert", "", "Client cert file path")
 	flags.StringVar(&clientKeyFile, "key", "", "Client key file path")
+	flags.StringVar(&serverAddress, "server", "https://localhost:8080", "Server address")
 
 	for _, arg := range []string{"ca", "cert", "key"} {
 		rootCmd.MarkPersistentFlagRequired(arg)
 	}
 
-	rootCmd.AddCommand(&cmdStart, &cmdStop, &cmdGet, &cmdGetLogs)
+	rootCmd.AddCommand(&cmdStart, &cmdStop, &cmdPause, &cmdResume, &cmdGet, &cmdGetLogs, &cmdList, &cmdShares, &cmdSchedule, &cmdWorkflow)
 }
 
 func makeClient() *httpClient.Client {
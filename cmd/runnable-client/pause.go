@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdPause = cobra.Command{
+		Use:   "pause [jobID]",
+		Short: "Freezes every process of the job, until it is resumed",
+		RunE:  pauseJob,
+		Args:  cobra.ExactArgs(1),
+	}
+)
+
+func pauseJob(cobraCmd *cobra.Command, args []string) error {
+	return makeClient().PauseJob(args[0])
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdResume = cobra.Command{
+		Use:   "resume [jobID]",
+		Short: "Lets a paused job carry on from where it was paused",
+		RunE:  resumeJob,
+		Args:  cobra.ExactArgs(1),
+	}
+)
+
+func resumeJob(cobraCmd *cobra.Command, args []string) error {
+	return makeClient().ResumeJob(args[0])
+}
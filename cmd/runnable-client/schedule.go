@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ambardhesi/runnable/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdSchedule = cobra.Command{
+		Use:   "schedule",
+		Short: "Manages schedules that start jobs on the Runnable server",
+	}
+	cmdScheduleCreate = cobra.Command{
+		Use: "create cron command [args...]",
+		Long: "Creates a schedule that starts a job every time the cron expression matches, eg \"0 * * * *\" for the start of every hour.\n" +
+			"Takes the same flags as start, apart from --deadline.",
+		Short: "Creates a schedule",
+		RunE:  createSchedule,
+		Args:  cobra.MinimumNArgs(2),
+	}
+	cmdScheduleList = cobra.Command{
+		Use:   "list",
+		Short: "Lists your schedules, or another owner's",
+		RunE:  listSchedules,
+		Args:  cobra.NoArgs,
+	}
+	cmdScheduleDelete = cobra.Command{
+		Use:   "delete [scheduleID]",
+		Short: "Deletes the schedule, jobs it already started keep running",
+		RunE:  deleteSchedule,
+		Args:  cobra.ExactArgs(1),
+	}
+	cmdSchedulePause = cobra.Command{
+		Use:   "pause [scheduleID]",
+		Short: "Stops the schedule from starting jobs until it is resumed",
+		RunE:  pauseSchedule,
+		Args:  cobra.ExactArgs(1),
+	}
+	cmdScheduleResume = cobra.Command{
+		Use:   "resume [scheduleID]",
+		Short: "Lets a paused schedule start jobs again",
+		RunE:  resumeSchedule,
+		Args:  cobra.ExactArgs(1),
+	}
+	overlap       string
+	scheduleOwner string
+)
+
+func init() {
+	addJobFlags(&cmdScheduleCreate)
+	cmdScheduleCreate.Flags().StringVar(&overlap, "overlap", "",
+		"What to do when the last job is still running: skip (the default), queue or allow")
+	cmdScheduleList.Flags().StringVar(&scheduleOwner, "owner", "",
+		"List this owner's schedules instead of your own, or * for every owner's. Needs a role that grants it")
+
+	cmdSchedule.AddCommand(&cmdScheduleCreate, &cmdScheduleList, &cmdScheduleDelete, &cmdSchedulePause, &cmdScheduleResume)
+}
+
+func createSchedule(cobraCmd *cobra.Command, args []string) error {
+	job, err := jobRequest(args[1:])
+	if err != nil {
+		return err
+	}
+
+	response, err := makeClient().CreateSchedule(server.CreateScheduleRequest{
+		Cron:    args[0],
+		Overlap: overlap,
+		Job:     job,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created schedule : %v\n", response.ScheduleID)
+	return nil
+}
+
+func listSchedules(cobraCmd *cobra.Command, args []string) error {
+	response, err := makeClient().ListSchedules(scheduleOwner)
+
+	if err != nil {
+		return err
+	}
+
+	// other owners' schedules are listed with who they belong to
+	owners := scheduleOwner != ""
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if owners {
+		fmt.Fprint(w, "OWNER\t")
+	}
+	fmt.Fprintln(w, "ID\tCRON\tOVERLAP\tNEXT RUN\tLAST JOB\tCOMMAND")
+	for _, schedule := range response.Schedules {
+		nextRun := "-"
+		if schedule.Paused {
+			nextRun = "paused"
+		} else if schedule.NextRun != nil {
+			nextRun = schedule.NextRun.Local().Format(time.RFC3339)
+		}
+
+		lastJob := schedule.LastJobID
+		if lastJob == "" {
+			lastJob = "-"
+		}
+
+		command := quoteArgs(append([]string{schedule.Command}, schedule.Args...))
+		if schedule.Shell {
+			command = "sh -c " + command
+		}
+		if owners {
+			fmt.Fprintf(w, "%v\t", schedule.OwnerID)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", schedule.ID, schedule.Cron, schedule.Overlap, nextRun, lastJob, command)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func deleteSchedule(cobraCmd *cobra.Command, args []string) error {
+	return makeClient().DeleteSchedule(args[0])
+}
+
+func pauseSchedule(cobraCmd *cobra.Command, args []string) error {
+	return makeClient().PauseSchedule(args[0])
+}
+
+func resumeSchedule(cobraCmd *cobra.Command, args []string) error {
+	return makeClient().ResumeSchedule(args[0])
+}
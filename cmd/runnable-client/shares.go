@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdShares = cobra.Command{
+		Use:   "shares",
+		Short: "Shows how running jobs are shared between owners. Needs the admin role.",
+		RunE:  getShares,
+		Args:  cobra.NoArgs,
+	}
+)
+
+func getShares(cobraCmd *cobra.Command, args []string) error {
+	response, err := makeClient().GetShares()
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OWNER\tWEIGHT\tSHARE\tUSAGE\tRUNNING\tQUEUED")
+	for _, owner := range response.Owners {
+		fmt.Fprintf(w, "%v\t%v\t%.0f%%\t%.0f%%\t%v\t%v\n",
+			owner.OwnerID, owner.Weight, owner.Share*100, owner.Usage*100, owner.Running, owner.Queued)
+	}
+	w.Flush()
+
+	return nil
+}
@@ -2,29 +2,209 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ambardhesi/runnable/internal/server"
 	"github.com/spf13/cobra"
 )
 
 var (
 	cmdStart = cobra.Command{
-		Use:   "start command [args...]",
+		Use: "start command [args...]",
+		Long: "Starts a job on the Runnable server. The command and its arguments are passed on exactly as given.\n" +
+			"With --shell, the command is a script run by /bin/sh -c, and the arguments are its $1 onwards.",
 		Short: "Starts a job on the Runnable server",
 		RunE:  startJob,
 		Args:  cobra.MinimumNArgs(1),
 	}
+	cpuMillis   int64
+	memoryBytes int64
+	ioLimits    []string
+	isolated    bool
+	hostNetwork bool
+	labels      []string
+	shell       bool
+	env         []string
+	clearEnv    bool
+	workingDir  string
+	uid         int64
+	gid         int64
+	groups      []uint
+	jobTimeout  string
+	deadline    string
+	priority    int
+	restart     string
+	maxRetries  int
+	backoff     string
+	maxBackoff  string
 )
 
+func init() {
+	addJobFlags(&cmdStart)
+}
+
+// Adds the flags that describe a job to the command, whose arguments are the job's command and its arguments.
+func addJobFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	// everything after the command belongs to it, not to us
+	flags.SetInterspersed(false)
+
+	flags.Int64Var(&cpuMillis, "cpu-millis", 0, "CPU limit in thousandths of a CPU (eg 500 for half a CPU)")
+	flags.Int64Var(&memoryBytes, "memory-bytes", 0, "Memory limit in bytes")
+	flags.StringArrayVar(&ioLimits, "io", nil,
+		"IO limit for a device, in the io.max format (eg \"8:0 rbps=1048576 wiops=120\"). Can be repeated")
+	flags.BoolVar(&isolated, "isolated", false, "Run the job in its own PID, mount, UTS and network namespaces")
+	flags.BoolVar(&hostNetwork, "host-network", false, "Keep an isolated job on the host network")
+	flags.BoolVar(&shell, "shell", false, "Run the command as a script with /bin/sh -c")
+	flags.StringArrayVar(&env, "env", nil, "Environment variable for the job in KEY=value format. Can be repeated")
+	flags.BoolVar(&clearEnv, "clear-env", false, "Start the job with only the --env variables, rather than the server's environment")
+	flags.StringVar(&workingDir, "working-dir", "", "Absolute path to run the job in")
+	flags.Int64Var(&uid, "uid", -1, "User ID to run the job as, needs --gid too")
+	flags.Int64Var(&gid, "gid", -1, "Group ID to run the job as, needs --uid too")
+	flags.UintSliceVar(&groups, "groups", nil, "Supplementary group IDs of the job (eg 100,101)")
+	flags.StringVar(&jobTimeout, "timeout", "", "How long the job may run for before it is stopped (eg 30m)")
+	flags.StringVar(&deadline, "deadline", "", "When the job has to have finished by, in RFC 3339 format (eg 2021-06-01T18:00:00Z)")
+	flags.IntVar(&priority, "priority", 0, "Queued jobs with a higher priority start before your others")
+	flags.StringVar(&restart, "restart", "", "Run the job again once it has finished: never, on-failure or always")
+	flags.IntVar(&maxRetries, "max-retries", 0, "Most times to restart the job, no limit if not set")
+	flags.StringVar(&backoff, "restart-backoff", "", "How long to wait before the first restart, doubled for every one after (eg 5s)")
+	flags.StringVar(&maxBackoff, "max-restart-backoff", "", "Longest to wait before a restart (eg 1m)")
+	flags.StringArrayVar(&labels, "label", nil, "Label for the job in key=value format, that it can be listed by. Can be repeated")
+}
+
 func startJob(cobraCmd *cobra.Command, args []string) error {
-	cmd := strings.Join(args, " ")
+	request, err := jobRequest(args)
+	if err != nil {
+		return err
+	}
 
-	jobID, err := makeClient().StartJob(cmd)
+	jobID, err := makeClient().StartJob(request)
 
 	if err != nil {
-		return nil
+		return err
 	}
 
 	fmt.Printf("Started job : %v\n", *jobID)
 	return nil
 }
+
+// Returns the request for a job running the given command and arguments, as set up by the job flags.
+func jobRequest(args []string) (server.StartJobRequest, error) {
+	request := server.StartJobRequest{
+		Command:     args[0],
+		Args:        args[1:],
+		Shell:       shell,
+		ClearEnv:    clearEnv,
+		WorkingDir:  workingDir,
+		Timeout:     jobTimeout,
+		Priority:    priority,
+		CPUMillis:   cpuMillis,
+		MemoryBytes: memoryBytes,
+		Isolated:    isolated,
+		HostNetwork: hostNetwork,
+	}
+
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return request, fmt.Errorf("invalid environment variable %q, expected KEY=value", e)
+		}
+
+		if request.Env == nil {
+			request.Env = make(map[string]string)
+		}
+		request.Env[kv[0]] = kv[1]
+	}
+
+	if uid >= 0 {
+		id := uint32(uid)
+		request.UID = &id
+	}
+	if gid >= 0 {
+		id := uint32(gid)
+		request.GID = &id
+	}
+	for _, g := range groups {
+		request.Groups = append(request.Groups, uint32(g))
+	}
+
+	if restart != "" {
+		request.Restart = &server.RestartPolicyRequest{
+			Policy:     restart,
+			MaxRetries: maxRetries,
+			Backoff:    backoff,
+			MaxBackoff: maxBackoff,
+		}
+	}
+
+	if deadline != "" {
+		d, err := time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			return request, fmt.Errorf("invalid deadline %q %v", deadline, err)
+		}
+		request.Deadline = &d
+	}
+
+	for _, label := range labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return request, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+
+		if request.Labels == nil {
+			request.Labels = make(map[string]string)
+		}
+		request.Labels[kv[0]] = kv[1]
+	}
+
+	for _, l := range ioLimits {
+		io, err := parseIOLimit(l)
+		if err != nil {
+			return request, err
+		}
+		request.IO = append(request.IO, io)
+	}
+
+	return request, nil
+}
+
+// parses an io.max style line, eg "8:0 rbps=1048576 wiops=120"
+func parseIOLimit(limit string) (server.IOLimitRequest, error) {
+	fields := strings.Fields(limit)
+	if len(fields) == 0 {
+		return server.IOLimitRequest{}, fmt.Errorf("empty io limit")
+	}
+
+	io := server.IOLimitRequest{
+		Device: fields[0],
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return io, fmt.Errorf("invalid io limit %q, expected key=value", field)
+		}
+
+		value, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil {
+			return io, fmt.Errorf("invalid io limit %q %v", field, err)
+		}
+
+		switch kv[0] {
+		case "rbps":
+			io.ReadBPS = value
+		case "wbps":
+			io.WriteBPS = value
+		case "riops":
+			io.ReadIOPS = value
+		case "wiops":
+			io.WriteIOPS = value
+		default:
+			return io, fmt.Errorf("unknown io limit %q", kv[0])
+		}
+	}
+
+	return io, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/ambardhesi/runnable/internal/server"
 	"github.com/spf13/cobra"
 )
 
@@ -11,12 +12,24 @@ var (
 		RunE:  stopJob,
 		Args:  cobra.ExactArgs(1),
 	}
+	stopSignal  string
+	stopTimeout string
 )
 
+func init() {
+	flags := cmdStop.Flags()
+
+	flags.StringVar(&stopSignal, "signal", "", "Signal to send first (eg SIGTERM). SIGTERM if only --timeout is set, otherwise the job is killed straight away")
+	flags.StringVar(&stopTimeout, "timeout", "", "How long to wait after the signal before killing the job (eg 30s)")
+}
+
 func stopJob(cobraCmd *cobra.Command, args []string) error {
 	jobID := args[0]
 
-	err := makeClient().StopJob(jobID)
+	err := makeClient().StopJob(jobID, server.StopJobRequest{
+		Signal:  stopSignal,
+		Timeout: stopTimeout,
+	})
 
 	if err != nil {
 		return err
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ambardhesi/runnable/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdWorkflow = cobra.Command{
+		Use:   "workflow",
+		Short: "Manages workflows, sets of jobs that run once the jobs they depend on succeed",
+	}
+	cmdWorkflowSubmit = cobra.Command{
+		Use: "submit file",
+		Long: "Submits the workflow in the JSON file (- for stdin) to the Runnable server, eg\n" +
+			"{\"onFailure\": \"skip\", \"jobs\": [{\"name\": \"build\", \"command\": \"make\"}, " +
+			"{\"name\": \"test\", \"dependsOn\": [\"build\"], \"command\": \"make\", \"args\": [\"test\"]}]}\n" +
+			"Every job takes the same fields as a job started on its own.",
+		Short: "Submits a workflow",
+		RunE:  submitWorkflow,
+		Args:  cobra.ExactArgs(1),
+	}
+	cmdWorkflowGet = cobra.Command{
+		Use:   "get [workflowID]",
+		Short: "Gets the status of a workflow and its jobs",
+		RunE:  getWorkflow,
+		Args:  cobra.ExactArgs(1),
+	}
+	cmdWorkflowList = cobra.Command{
+		Use:   "list",
+		Short: "Lists your workflows, or another owner's",
+		RunE:  listWorkflows,
+		Args:  cobra.NoArgs,
+	}
+	cmdWorkflowStop = cobra.Command{
+		Use:   "stop [workflowID]",
+		Short: "Stops the workflow's running jobs, and skips the rest",
+		RunE:  stopWorkflow,
+		Args:  cobra.ExactArgs(1),
+	}
+	workflowOwner string
+)
+
+func init() {
+	cmdWorkflowList.Flags().StringVar(&workflowOwner, "owner", "",
+		"List this owner's workflows instead of your own, or * for every owner's. Needs a role that grants it")
+
+	cmdWorkflow.AddCommand(&cmdWorkflowSubmit, &cmdWorkflowGet, &cmdWorkflowList, &cmdWorkflowStop)
+}
+
+func submitWorkflow(cobraCmd *cobra.Command, args []string) error {
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	var request server.SubmitWorkflowRequest
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		return fmt.Errorf("invalid workflow %v", err)
+	}
+
+	response, err := makeClient().SubmitWorkflow(request)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Submitted workflow : %v\n", response.WorkflowID)
+	return nil
+}
+
+func getWorkflow(cobraCmd *cobra.Command, args []string) error {
+	response, err := makeClient().GetWorkflow(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Workflow : %v %v\n\n", response.ID, response.State)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tDEPENDS ON\tJOB\tERROR")
+	for _, job := range response.Jobs {
+		dependsOn, jobID := strings.Join(job.DependsOn, ","), job.JobID
+		if dependsOn == "" {
+			dependsOn = "-"
+		}
+		if jobID == "" {
+			jobID = "-"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", job.Name, job.State, dependsOn, jobID, job.Error)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func listWorkflows(cobraCmd *cobra.Command, args []string) error {
+	response, err := makeClient().ListWorkflows(workflowOwner)
+
+	if err != nil {
+		return err
+	}
+
+	// other owners' workflows are listed with who they belong to
+	owners := workflowOwner != ""
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if owners {
+		fmt.Fprint(w, "OWNER\t")
+	}
+	fmt.Fprintln(w, "ID\tSTATE\tCREATED\tJOBS")
+	for _, workflow := range response.Workflows {
+		names := make([]string, len(workflow.Jobs))
+		for i, job := range workflow.Jobs {
+			names[i] = job.Name
+		}
+		if owners {
+			fmt.Fprintf(w, "%v\t", workflow.OwnerID)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", workflow.ID, workflow.State,
+			workflow.CreatedAt.Local().Format(time.RFC3339), strings.Join(names, ","))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func stopWorkflow(cobraCmd *cobra.Command, args []string) error {
+	return makeClient().StopWorkflow(args[0])
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ambardhesi/runnable/internal/server"
+)
+
+// A duration written like 30s or 1h in the config file, rather than in nanoseconds.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("Duration must be a string like 30s, got %s", b)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = duration(parsed)
+	return nil
+}
+
+// The server config as it is written in the config file, which has the same fields.
+// Its durations hide the ones of server.Config.
+type configFile struct {
+	server.Config
+	MaxRuntime         duration
+	CRLRefreshInterval duration
+	CertWatchInterval  duration
+}
+
+// Reads the JSON config file over the given config, so that the fields it leaves out keep their value.
+func readConfig(filePath string, config server.Config) (server.Config, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	parsed := configFile{
+		Config:             config,
+		MaxRuntime:         duration(config.MaxRuntime),
+		CRLRefreshInterval: duration(config.CRLRefreshInterval),
+		CertWatchInterval:  duration(config.CertWatchInterval),
+	}
+
+	decoder := json.NewDecoder(file)
+	// a misspelt field would otherwise quietly leave a feature off
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&parsed)
+	if err != nil {
+		return config, fmt.Errorf("Invalid config file %v %w", filePath, err)
+	}
+
+	config = parsed.Config
+	config.MaxRuntime = time.Duration(parsed.MaxRuntime)
+	config.CRLRefreshInterval = time.Duration(parsed.CRLRefreshInterval)
+	config.CertWatchInterval = time.Duration(parsed.CertWatchInterval)
+	return config, nil
+}
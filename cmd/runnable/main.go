@@ -1,21 +1,51 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ambardhesi/runnable/internal/server"
+	"github.com/ambardhesi/runnable/pkg/runnable"
 )
 
 func main() {
+	// isolated jobs re-execute this binary as their init process
+	if os.Args[0] == runnable.InitCommand {
+		runnable.RunInit()
+	}
+
+	configFilePath := flag.String("config", "", "JSON file with the server config, its fields override the defaults")
+	flag.Parse()
+
 	config := server.Config{
-		// TODO configure this to be _really_ configurable, and not hardcoded
 		Port:           8080,
 		LogDir:         "logs",
 		CertFilePath:   "certs/svr-cert.pem",
 		KeyFilePath:    "certs/svr-key.pem",
 		CaCertFilePath: "certs/ca-cert.pem",
 		TestMode:       false,
+		// jobs run without resource limits, as setting up cgroups needs root and cgroup v2 delegated to the server
+		CgroupRoot:        "",
+		JobStoreFile:      "jobs.log",
+		ScheduleStoreFile: "schedules.json",
+		CertWatchInterval: 30 * time.Second,
+	}
+
+	// nobody, so that jobs which do not ask for a user are not run as root. Any other server can only run
+	// jobs as its own user
+	if os.Geteuid() == 0 {
+		config.DefaultCredential = &runnable.Credential{UID: 65534, GID: 65534}
+	}
+
+	if *configFilePath != "" {
+		var err error
+		config, err = readConfig(*configFilePath, config)
+		if err != nil {
+			log.Printf("Failed to read config %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	s, err := server.NewServer(config)
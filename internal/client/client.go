@@ -3,7 +3,10 @@ package client
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/ambardhesi/runnable/internal/server"
 	"github.com/go-resty/resty/v2"
@@ -38,11 +41,7 @@ func NewClient(config Config) (*Client, error) {
 	}, nil
 }
 
-func (c *Client) StartJob(cmd string) (*string, error) {
-	request := server.StartJobRequest{
-		Command: cmd,
-	}
-
+func (c *Client) StartJob(request server.StartJobRequest) (*string, error) {
 	resp, err := c.HttpClient.R().
 		SetHeader("Content-Type", "application/json").
 		SetBody(request).
@@ -61,8 +60,10 @@ func (c *Client) StartJob(cmd string) (*string, error) {
 
 }
 
-func (c *Client) StopJob(jobID string) error {
+func (c *Client) StopJob(jobID string, request server.StopJobRequest) error {
 	resp, err := c.HttpClient.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
 		Post(c.Config.ServerAddress + "/job/" + jobID + "/stop")
 
 	if err != nil {
@@ -76,6 +77,29 @@ func (c *Client) StopJob(jobID string) error {
 	return nil
 }
 
+func (c *Client) PauseJob(jobID string) error {
+	return c.postJob(jobID, "pause")
+}
+
+func (c *Client) ResumeJob(jobID string) error {
+	return c.postJob(jobID, "resume")
+}
+
+func (c *Client) postJob(jobID string, action string) error {
+	resp, err := c.HttpClient.R().
+		Post(c.Config.ServerAddress + "/job/" + jobID + "/" + action)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return errors.New(string(resp.Body()))
+	}
+
+	return nil
+}
+
 func (c *Client) Get(jobID string) (*string, error) {
 	resp, err := c.HttpClient.R().
 		Get(c.Config.ServerAddress + "/job/" + jobID)
@@ -92,8 +116,220 @@ func (c *Client) Get(jobID string) (*string, error) {
 	return &body, nil
 }
 
-func (c *Client) GetLogs(jobID string) (*string, error) {
+func (c *Client) ListJobs(request server.ListJobsRequest) (*server.ListJobsResponse, error) {
+	query := url.Values{}
+	for _, state := range request.States {
+		query.Add("state", state)
+	}
+	for _, label := range request.Labels {
+		query.Add("label", label)
+	}
+	for key, value := range map[string]string{
+		"since":    request.Since,
+		"until":    request.Until,
+		"command":  request.CommandPrefix,
+		"cursor":   request.Cursor,
+		"order":    request.Order,
+		"schedule": request.ScheduleID,
+		"workflow": request.WorkflowID,
+		"owner":    request.OwnerID,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if request.Limit != 0 {
+		query.Set("limit", strconv.Itoa(request.Limit))
+	}
+
+	var response server.ListJobsResponse
+	resp, err := c.HttpClient.R().
+		SetQueryParamsFromValues(query).
+		SetResult(&response).
+		Get(c.Config.ServerAddress + "/jobs")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+func (c *Client) GetShares() (*server.GetSharesResponse, error) {
+	var response server.GetSharesResponse
+	resp, err := c.HttpClient.R().
+		SetResult(&response).
+		Get(c.Config.ServerAddress + "/admin/shares")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+func (c *Client) CreateSchedule(request server.CreateScheduleRequest) (*server.CreateScheduleResponse, error) {
+	var response server.CreateScheduleResponse
+	resp, err := c.HttpClient.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		SetResult(&response).
+		Post(c.Config.ServerAddress + "/schedules")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+// Lists the client's own schedules, unless ownerID is another owner's or *.
+func (c *Client) ListSchedules(ownerID string) (*server.ListSchedulesResponse, error) {
+	var response server.ListSchedulesResponse
+	request := c.HttpClient.R().SetResult(&response)
+	if ownerID != "" {
+		request.SetQueryParam("owner", ownerID)
+	}
+	resp, err := request.
+		Get(c.Config.ServerAddress + "/schedules")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+func (c *Client) DeleteSchedule(scheduleID string) error {
 	resp, err := c.HttpClient.R().
+		Delete(c.Config.ServerAddress + "/schedules/" + scheduleID)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return errors.New(string(resp.Body()))
+	}
+
+	return nil
+}
+
+func (c *Client) PauseSchedule(scheduleID string) error {
+	return c.postSchedule(scheduleID, "pause")
+}
+
+func (c *Client) ResumeSchedule(scheduleID string) error {
+	return c.postSchedule(scheduleID, "resume")
+}
+
+func (c *Client) postSchedule(scheduleID string, action string) error {
+	resp, err := c.HttpClient.R().
+		Post(c.Config.ServerAddress + "/schedules/" + scheduleID + "/" + action)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return errors.New(string(resp.Body()))
+	}
+
+	return nil
+}
+
+func (c *Client) SubmitWorkflow(request server.SubmitWorkflowRequest) (*server.SubmitWorkflowResponse, error) {
+	var response server.SubmitWorkflowResponse
+	resp, err := c.HttpClient.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		SetResult(&response).
+		Post(c.Config.ServerAddress + "/workflows")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+func (c *Client) GetWorkflow(workflowID string) (*server.GetWorkflowResponse, error) {
+	var response server.GetWorkflowResponse
+	resp, err := c.HttpClient.R().
+		SetResult(&response).
+		Get(c.Config.ServerAddress + "/workflows/" + workflowID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+// Lists the client's own workflows, unless ownerID is another owner's or *.
+func (c *Client) ListWorkflows(ownerID string) (*server.ListWorkflowsResponse, error) {
+	var response server.ListWorkflowsResponse
+	request := c.HttpClient.R().SetResult(&response)
+	if ownerID != "" {
+		request.SetQueryParam("owner", ownerID)
+	}
+	resp, err := request.
+		Get(c.Config.ServerAddress + "/workflows")
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, errors.New(string(resp.Body()))
+	}
+
+	return &response, nil
+}
+
+func (c *Client) StopWorkflow(workflowID string) error {
+	resp, err := c.HttpClient.R().
+		Post(c.Config.ServerAddress + "/workflows/" + workflowID + "/stop")
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return errors.New(string(resp.Body()))
+	}
+
+	return nil
+}
+
+func (c *Client) GetLogs(jobID string, request server.GetLogsRequest) (*string, error) {
+	request.Follow = false
+	resp, err := c.HttpClient.R().
+		SetQueryParams(logsQuery(request)).
 		Get(c.Config.ServerAddress + "/job/" + jobID + "/logs")
 
 	if err != nil {
@@ -107,3 +343,41 @@ func (c *Client) GetLogs(jobID string) (*string, error) {
 	body := string(resp.Body())
 	return &body, nil
 }
+
+// Streams the job's logs to w as they are written, until the job has finished.
+func (c *Client) FollowLogs(jobID string, request server.GetLogsRequest, w io.Writer) error {
+	request.Follow = true
+	resp, err := c.HttpClient.R().
+		SetQueryParams(logsQuery(request)).
+		SetDoNotParseResponse(true).
+		Get(c.Config.ServerAddress + "/job/" + jobID + "/logs")
+
+	if err != nil {
+		return err
+	}
+
+	body := resp.RawBody()
+	defer body.Close()
+
+	if resp.StatusCode() != http.StatusOK {
+		b, _ := io.ReadAll(body)
+		return errors.New(string(b))
+	}
+
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func logsQuery(request server.GetLogsRequest) map[string]string {
+	query := map[string]string{
+		"follow":     strconv.FormatBool(request.Follow),
+		"timestamps": strconv.FormatBool(request.Timestamps),
+	}
+	if request.Stream != "" {
+		query["stream"] = request.Stream
+	}
+	if request.Attempt != 0 {
+		query["attempt"] = strconv.Itoa(request.Attempt)
+	}
+	return query
+}
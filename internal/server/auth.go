@@ -5,29 +5,153 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
 )
 
-func GetTLSConfig(svrCertFile, svrKeyFile, caCertFile string) (*tls.Config, error) {
-	svrCert, err := tls.LoadX509KeyPair(svrCertFile, svrKeyFile)
+// The server's cert and key, and the CA bundle client certs are checked against, which can be
+// reloaded while the server is running. Connections that are already open keep what they started with.
+type TLSFiles struct {
+	certFile   string
+	keyFile    string
+	caCertFile string
+	cert       *tls.Certificate
+	caCertPool *x509.CertPool
+	// when the files were last changed, as of the last check
+	modTimes map[string]time.Time
+	lock     sync.RWMutex
+}
+
+func LoadTLSFiles(certFile, keyFile, caCertFile string) (*TLSFiles, error) {
+	files := &TLSFiles{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		caCertFile: caCertFile,
+	}
+
+	files.modTimes = files.stat()
+	err := files.Reload()
 	if err != nil {
 		return nil, err
 	}
 
-	caCert, err := ioutil.ReadFile(caCertFile)
+	return files, nil
+}
+
+// Reads the files again. What was loaded before is kept if any of them can't be read,
+// eg when the cert has been replaced but the key not yet.
+func (files *TLSFiles) Reload() error {
+	cert, err := tls.LoadX509KeyPair(files.certFile, files.keyFile)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	caCert, err := ioutil.ReadFile(files.caCertFile)
+	if err != nil {
+		return err
 	}
 
 	caCertPool := x509.NewCertPool()
 	ok := caCertPool.AppendCertsFromPEM(caCert)
 	if !ok {
-		return nil, fmt.Errorf("Failed to add ca cert to pool")
+		return fmt.Errorf("Failed to add ca cert to pool")
+	}
+
+	files.lock.Lock()
+	defer files.lock.Unlock()
+
+	files.cert = &cert
+	files.caCertPool = caCertPool
+	return nil
+}
+
+// Reloads the files whenever any of them has changed, checking every interval, until stop is closed.
+func (files *TLSFiles) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			modTimes := files.stat()
+			if changed(files.modTimes, modTimes) {
+				// not tried again until the files change again, the next change is likely the one that fixes them
+				files.modTimes = modTimes
+				err := files.Reload()
+				if err != nil {
+					log.Printf("Failed to reload server cert %v\n", err)
+				} else {
+					log.Printf("Reloaded server cert and CA\n")
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Meant for tls.Config.GetCertificate, returns the server cert last loaded.
+func (files *TLSFiles) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	files.lock.RLock()
+	defer files.lock.RUnlock()
+
+	return files.cert, nil
+}
+
+func (files *TLSFiles) CaCertPool() *x509.CertPool {
+	files.lock.RLock()
+	defer files.lock.RUnlock()
+
+	return files.caCertPool
+}
+
+// Files that can't be stat'ed are left out.
+func (files *TLSFiles) stat() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, file := range []string{files.certFile, files.keyFile, files.caCertFile} {
+		info, err := os.Stat(file)
+		if err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+func changed(before, after map[string]time.Time) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for file, modTime := range after {
+		if !modTime.Equal(before[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Every new connection gets the cert and CA bundle last loaded from files.
+// Client certs revoked by rl are rejected, if it is not nil.
+func GetTLSConfig(files *TLSFiles, rl *RevocationList) *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS13,
+		GetCertificate: files.GetCertificate,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+	}
+	if rl != nil {
+		config.VerifyPeerCertificate = rl.VerifyPeerCertificate
+	}
+
+	// the CA bundle can only be swapped by handing out a whole config
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		clientConfig := config.Clone()
+		clientConfig.GetConfigForClient = nil
+		// what http.Server would otherwise have added to the config it was given
+		clientConfig.NextProtos = []string{"h2", "http/1.1"}
+		clientConfig.ClientCAs = files.CaCertPool()
+		return clientConfig, nil
 	}
 
-	return &tls.Config{
-		MinVersion:   tls.VersionTLS13,
-		Certificates: []tls.Certificate{svrCert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		ClientCAs:    caCertPool,
-	}, nil
+	return config
 }
@@ -0,0 +1,84 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/internal/server"
+)
+
+func writeKey(path string, key *ecdsa.PrivateKey) {
+	der, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+}
+
+func TestTLSFilesReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "svr-cert.pem"), filepath.Join(dir, "svr-key.pem"), filepath.Join(dir, "ca-cert.pem")
+
+	ca := newTestCA(t, "ca")
+	svrCert, svrKey := ca.issue(t, 10, "localhost")
+	writeCert(certFile, svrCert)
+	writeKey(keyFile, svrKey)
+	writeCert(caFile, ca.cert)
+
+	files, err := server.LoadTLSFiles(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatalf("expected TLS files to load, got %v", err)
+	}
+	config := server.GetTLSConfig(files, nil)
+
+	// what a new connection would be handed
+	current := func() (*x509.Certificate, *x509.CertPool) {
+		clientConfig, _ := config.GetConfigForClient(nil)
+		cert, _ := clientConfig.GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf, clientConfig.ClientCAs
+	}
+
+	if cert, _ := current(); cert.SerialNumber.Int64() != 10 {
+		t.Fatalf("expected cert 10, got %v", cert.SerialNumber)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go files.Watch(10*time.Millisecond, stop)
+
+	// a new cert from a new CA, which client certs are then checked against
+	newCA := newTestCA(t, "new ca")
+	svrCert, svrKey = newCA.issue(t, 11, "localhost")
+	writeCert(certFile, svrCert)
+	writeKey(keyFile, svrKey)
+	writeCert(caFile, newCA.cert)
+
+	deadline := time.Now().Add(2 * time.Second)
+	cert, pool := current()
+	for cert.SerialNumber.Int64() != 11 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		cert, pool = current()
+	}
+	if cert.SerialNumber.Int64() != 11 {
+		t.Fatalf("expected the changed cert to be picked up")
+	}
+
+	clientCert, _ := newCA.issue(t, 12, "alice")
+	_, err = clientCert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if err != nil {
+		t.Errorf("expected client certs of the new CA to be accepted, got %v", err)
+	}
+
+	// a cert that does not go with the key is not loaded
+	otherCert, _ := newCA.issue(t, 13, "localhost")
+	writeCert(certFile, otherCert)
+	if err := files.Reload(); err == nil {
+		t.Errorf("expected a cert without its key not to load")
+	}
+	if cert, _ := current(); cert.SerialNumber.Int64() != 11 {
+		t.Errorf("expected the last good cert to be kept, got %v", cert.SerialNumber)
+	}
+}
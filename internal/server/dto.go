@@ -1,32 +1,688 @@
 package server
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ambardhesi/runnable/pkg/runnable"
 )
 
 type StartJobRequest struct {
+	// The program to run, or a shell script if Shell is set.
 	Command string `json:"command" binding:"required"`
+	// Passed to the program as they are, without any splitting or quoting.
+	Args []string `json:"args,omitempty"`
+	// Runs Command with /bin/sh -c, with Args as the script's $1 onwards.
+	Shell       bool              `json:"shell,omitempty"`
+	CPUMillis   int64             `json:"cpuMillis,omitempty"`
+	MemoryBytes int64             `json:"memoryBytes,omitempty"`
+	IO          []IOLimitRequest  `json:"io,omitempty"`
+	Isolated    bool              `json:"isolated,omitempty"`
+	HostNetwork bool              `json:"hostNetwork,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	// Starts the job with only the variables in Env, rather than on top of the server's.
+	ClearEnv   bool   `json:"clearEnv,omitempty"`
+	WorkingDir string `json:"workingDir,omitempty"`
+	// Who the job runs as. UID and GID have to be set together, and must be allowed by the server.
+	UID    *uint32  `json:"uid,omitempty"`
+	GID    *uint32  `json:"gid,omitempty"`
+	Groups []uint32 `json:"groups,omitempty"`
+	// How long the job may run for, eg 30m.
+	Timeout string `json:"timeout,omitempty"`
+	// When the job has to have finished by.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Queued jobs with a higher priority start before the owner's others, the default is 0.
+	Priority int `json:"priority,omitempty"`
+	// Whether the job is run again once it has finished. It never is if not set.
+	Restart *RestartPolicyRequest `json:"restart,omitempty"`
+}
+
+type RestartPolicyRequest struct {
+	// never, on-failure or always.
+	Policy     string `json:"policy" binding:"required"`
+	MaxRetries int    `json:"maxRetries,omitempty"`
+	// How long to wait before the first restart, eg 1s. Doubled for every restart after that, up to MaxBackoff.
+	Backoff    string `json:"backoff,omitempty"`
+	MaxBackoff string `json:"maxBackoff,omitempty"`
+}
+
+type IOLimitRequest struct {
+	Device    string `json:"device" binding:"required"`
+	ReadBPS   int64  `json:"readBps,omitempty"`
+	WriteBPS  int64  `json:"writeBps,omitempty"`
+	ReadIOPS  int64  `json:"readIops,omitempty"`
+	WriteIOPS int64  `json:"writeIops,omitempty"`
+}
+
+func (request StartJobRequest) Limits() runnable.ResourceLimits {
+	limits := runnable.ResourceLimits{
+		CPUMillis:   request.CPUMillis,
+		MemoryBytes: request.MemoryBytes,
+	}
+
+	for _, io := range request.IO {
+		limits.IO = append(limits.IO, runnable.IOLimit{
+			Device:    io.Device,
+			ReadBPS:   io.ReadBPS,
+			WriteBPS:  io.WriteBPS,
+			ReadIOPS:  io.ReadIOPS,
+			WriteIOPS: io.WriteIOPS,
+		})
+	}
+
+	return limits
+}
+
+// Returns nil if the request does not ask for a user.
+func (request StartJobRequest) Credential() (*runnable.Credential, error) {
+	if request.UID == nil && request.GID == nil && len(request.Groups) == 0 {
+		return nil, nil
+	}
+
+	if request.UID == nil || request.GID == nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "StartJobRequest.Credential",
+			Message: "Both uid and gid have to be set to run as another user.",
+		}
+	}
+
+	return &runnable.Credential{
+		UID:    *request.UID,
+		GID:    *request.GID,
+		Groups: request.Groups,
+	}, nil
+}
+
+// Returns how long the job may run for and when it has to finish by, zero if not set.
+// Jobs that set neither get maxRuntime as their timeout, and none may run for longer than it (if set).
+func (request StartJobRequest) Runtime(maxRuntime time.Duration) (time.Duration, time.Time, error) {
+	op := "StartJobRequest.Runtime"
+
+	var timeout time.Duration
+	if request.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(request.Timeout)
+		if err != nil || timeout <= 0 {
+			return 0, time.Time{}, &runnable.Error{
+				Code:    runnable.EINVALID,
+				Op:      op,
+				Message: fmt.Sprintf("Invalid timeout %q.", request.Timeout),
+			}
+		}
+	}
+
+	var deadline time.Time
+	if request.Deadline != nil {
+		deadline = *request.Deadline
+	}
+
+	if maxRuntime == 0 {
+		return timeout, deadline, nil
+	}
+
+	if timeout == 0 && deadline.IsZero() {
+		return maxRuntime, deadline, nil
+	}
+
+	if timeout > maxRuntime || (timeout == 0 && time.Until(deadline) > maxRuntime) {
+		return 0, time.Time{}, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("Jobs can't run for longer than %v.", maxRuntime),
+		}
+	}
+
+	return timeout, deadline, nil
+}
+
+func (request StartJobRequest) RestartPolicy() (runnable.RestartPolicy, error) {
+	var policy runnable.RestartPolicy
+	if request.Restart == nil {
+		return policy, nil
+	}
+
+	policy.Mode = runnable.RestartMode(request.Restart.Policy)
+	policy.MaxRetries = request.Restart.MaxRetries
+
+	for _, backoff := range []struct {
+		value string
+		dest  *time.Duration
+	}{
+		{request.Restart.Backoff, &policy.Backoff},
+		{request.Restart.MaxBackoff, &policy.MaxBackoff},
+	} {
+		if backoff.value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(backoff.value)
+		if err != nil || d <= 0 {
+			return policy, &runnable.Error{
+				Code:    runnable.EINVALID,
+				Op:      "StartJobRequest.RestartPolicy",
+				Message: fmt.Sprintf("Invalid restart backoff %q.", backoff.value),
+			}
+		}
+		*backoff.dest = d
+	}
+
+	return policy, policy.Validate()
 }
 
 type StartJobResponse struct {
 	JobID string `json:"jobID"`
 }
 
+type StopJobRequest struct {
+	// Signal to send first, eg SIGTERM. SIGTERM if only Timeout is set, otherwise the job is killed straight away.
+	Signal string `json:"signal,omitempty"`
+	// How long to wait for the job to exit after Signal before killing it, eg 30s.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+func (request StopJobRequest) StopOptions() (runnable.StopOptions, error) {
+	var opts runnable.StopOptions
+
+	if request.Signal != "" {
+		sig, err := runnable.ParseSignal(request.Signal)
+		if err != nil {
+			return opts, err
+		}
+		opts.Signal = sig
+	}
+
+	if request.Timeout != "" {
+		timeout, err := time.ParseDuration(request.Timeout)
+		if err != nil || timeout < 0 {
+			return opts, &runnable.Error{
+				Code:    runnable.EINVALID,
+				Op:      "StopJobRequest.StopOptions",
+				Message: fmt.Sprintf("Invalid timeout %q.", request.Timeout),
+			}
+		}
+		opts.GracePeriod = timeout
+	}
+
+	return opts, nil
+}
+
+type GetLogsRequest struct {
+	// Keep streaming logs as they are written, until the job has finished.
+	Follow bool `form:"follow"`
+	// stdout, stderr or both. Both if not set.
+	Stream string `form:"stream"`
+	// Prefix every line with the time it was captured.
+	Timestamps bool `form:"timestamps"`
+	// Only get the logs of this attempt at running the job, 1 being the first. Every attempt's if not set.
+	Attempt int `form:"attempt"`
+}
+
+func (request GetLogsRequest) LogOptions() (runnable.LogOptions, error) {
+	opts := runnable.LogOptions{
+		Follow:     request.Follow,
+		Timestamps: request.Timestamps,
+		Attempt:    request.Attempt,
+	}
+
+	switch runnable.LogStream(request.Stream) {
+	case "", "both":
+	case runnable.Stdout, runnable.Stderr:
+		opts.Stream = runnable.LogStream(request.Stream)
+	default:
+		return opts, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "GetLogsRequest.LogOptions",
+			Message: fmt.Sprintf("Invalid stream %q, must be stdout, stderr or both.", request.Stream),
+		}
+	}
+
+	return opts, nil
+}
+
 type GetJobResponse struct {
 	State     string    `json:"state"`
 	ExitCode  int       `json:"exitCode"`
 	StartTime time.Time `json:"startTime"`
 	EndTime   time.Time `json:"endTime"`
+	Signal    string    `json:"signal,omitempty"`
+	// Whether the process dumped core when it was killed by the signal.
+	CoreDumped bool `json:"coreDumped,omitempty"`
+	// Why the job, or its last attempt, ended: Exited, Signaled, OOMKilled, Stopped, TimedOut, StartFailed,
+	// WaitFailed or Lost.
+	Reason string `json:"reason,omitempty"`
+	// How long the job ran for, eg 1m30s, once it has finished.
+	Elapsed string `json:"elapsed,omitempty"`
+	// Place of a Queued job among its owner's queued jobs, 1 is started first.
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// Every time the job has been run, oldest first.
+	Attempts []AttemptResponse `json:"attempts,omitempty"`
+	// When a Restarting job is run again.
+	NextAttempt *time.Time `json:"nextAttempt,omitempty"`
+	// What the last attempt to finish used.
+	Usage *UsageResponse `json:"usage,omitempty"`
+	// What a running or paused job is using right now, if it has a cgroup.
+	Live *LiveUsageResponse `json:"live,omitempty"`
+}
+
+type AttemptResponse struct {
+	// 1 for the first attempt, which is what to get its logs with.
+	Attempt    int            `json:"attempt"`
+	ExitCode   int            `json:"exitCode"`
+	StartTime  time.Time      `json:"startTime"`
+	EndTime    time.Time      `json:"endTime"`
+	Signal     string         `json:"signal,omitempty"`
+	CoreDumped bool           `json:"coreDumped,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+	Usage      *UsageResponse `json:"usage,omitempty"`
+}
+
+type UsageResponse struct {
+	// CPU times, eg 1.5s.
+	UserCPU                    string `json:"userCPU"`
+	SystemCPU                  string `json:"systemCPU"`
+	VoluntaryContextSwitches   int64  `json:"voluntaryContextSwitches"`
+	InvoluntaryContextSwitches int64  `json:"involuntaryContextSwitches"`
+	// Of the job's cgroup, 0 if it has none.
+	PeakMemoryBytes int64 `json:"peakMemoryBytes"`
+}
+
+func fromUsage(usage *runnable.ResourceUsage) *UsageResponse {
+	if usage == nil {
+		return nil
+	}
+
+	return &UsageResponse{
+		UserCPU:                    usage.UserCPU.String(),
+		SystemCPU:                  usage.SystemCPU.String(),
+		PeakMemoryBytes:            usage.PeakMemoryBytes,
+		VoluntaryContextSwitches:   usage.VoluntaryContextSwitches,
+		InvoluntaryContextSwitches: usage.InvoluntaryContextSwitches,
+	}
+}
+
+type LiveUsageResponse struct {
+	MemoryBytes     int64 `json:"memoryBytes"`
+	PeakMemoryBytes int64 `json:"peakMemoryBytes"`
+	// CPU time used so far, eg 1.5s.
+	CPUUsage     string `json:"cpuUsage"`
+	IOReadBytes  int64  `json:"ioReadBytes"`
+	IOWriteBytes int64  `json:"ioWriteBytes"`
 }
 
 func FromJob(job *runnable.Job) GetJobResponse {
 	status := job.Status()
-	return GetJobResponse{
-		State:     string(status.State),
-		ExitCode:  status.ExitCode,
-		StartTime: status.StartTime,
-		EndTime:   status.EndTime,
+	response := GetJobResponse{
+		State:         string(status.State),
+		ExitCode:      status.ExitCode,
+		StartTime:     status.StartTime,
+		EndTime:       status.EndTime,
+		Signal:        status.Signal,
+		CoreDumped:    status.CoreDumped,
+		Reason:        string(status.Reason),
+		QueuePosition: status.QueuePosition,
+		Usage:         fromUsage(status.Usage),
+	}
+	if status.Live != nil {
+		response.Live = &LiveUsageResponse{
+			MemoryBytes:     status.Live.MemoryBytes,
+			PeakMemoryBytes: status.Live.PeakMemoryBytes,
+			CPUUsage:        status.Live.CPUUsage.String(),
+			IOReadBytes:     status.Live.IOReadBytes,
+			IOWriteBytes:    status.Live.IOWriteBytes,
+		}
+	}
+	if status.Elapsed != 0 {
+		response.Elapsed = status.Elapsed.String()
+	}
+	if !status.NextAttempt.IsZero() {
+		response.NextAttempt = &status.NextAttempt
+	}
+	for i, attempt := range status.Attempts {
+		response.Attempts = append(response.Attempts, AttemptResponse{
+			Attempt:    i + 1,
+			ExitCode:   attempt.ExitCode,
+			StartTime:  attempt.StartTime,
+			EndTime:    attempt.EndTime,
+			Signal:     attempt.Signal,
+			CoreDumped: attempt.CoreDumped,
+			Reason:     string(attempt.Reason),
+			Usage:      fromUsage(attempt.Usage),
+		})
+	}
+	return response
+}
+
+type ListJobsRequest struct {
+	// Only list jobs in one of these states.
+	States []string `form:"state"`
+	// Only list jobs started at or after this time, in RFC 3339 format.
+	Since string `form:"since"`
+	// Only list jobs started before this time, in RFC 3339 format.
+	Until         string `form:"until"`
+	CommandPrefix string `form:"command"`
+	// Only list jobs with all of these labels, each in key=value format.
+	Labels []string `form:"label"`
+	Limit  int      `form:"limit"`
+	// The nextCursor of the previous page.
+	Cursor string `form:"cursor"`
+	// asc lists the oldest jobs first, desc (the default) the newest.
+	Order string `form:"order"`
+	// Only list jobs started by this schedule.
+	ScheduleID string `form:"schedule"`
+	// Only list jobs of this workflow.
+	WorkflowID string `form:"workflow"`
+	// List the jobs of this owner, or * for every owner, instead of your own.
+	OwnerID string `form:"owner"`
+}
+
+func (request ListJobsRequest) ListOptions() (runnable.ListOptions, error) {
+	op := "ListJobsRequest.ListOptions"
+	opts := runnable.ListOptions{
+		Filter: runnable.JobFilter{
+			CommandPrefix: request.CommandPrefix,
+			ScheduleID:    request.ScheduleID,
+			WorkflowID:    request.WorkflowID,
+			OwnerID:       request.OwnerID,
+		},
+		Limit:  request.Limit,
+		Cursor: request.Cursor,
+	}
+
+	for _, state := range request.States {
+		opts.Filter.States = append(opts.Filter.States, runnable.State(state))
+	}
+
+	var err error
+	opts.Filter.StartedAfter, err = parseTime(op, "since", request.Since)
+	if err != nil {
+		return opts, err
 	}
+
+	opts.Filter.StartedBefore, err = parseTime(op, "until", request.Until)
+	if err != nil {
+		return opts, err
+	}
+
+	if len(request.Labels) > 0 {
+		opts.Filter.Labels = make(map[string]string)
+	}
+	for _, label := range request.Labels {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return opts, &runnable.Error{
+				Code:    runnable.EINVALID,
+				Op:      op,
+				Message: fmt.Sprintf("Invalid label %q, must be key=value.", label),
+			}
+		}
+		opts.Filter.Labels[kv[0]] = kv[1]
+	}
+
+	switch request.Order {
+	case "", "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		return opts, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("Invalid order %q, must be asc or desc.", request.Order),
+		}
+	}
+
+	return opts, nil
+}
+
+func parseTime(op string, name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("Invalid %v time %q, must be in RFC 3339 format.", name, value),
+		}
+	}
+
+	return t, nil
+}
+
+type ListJobsResponse struct {
+	Jobs []ListedJob `json:"jobs"`
+	// Pass as cursor to get the next page, empty if this was the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type ListedJob struct {
+	ID       string            `json:"id"`
+	OwnerID  string            `json:"ownerID"`
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Shell    bool              `json:"shell,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Priority int               `json:"priority,omitempty"`
+	// The schedule that started the job, if any.
+	ScheduleID string `json:"scheduleID,omitempty"`
+	// The workflow the job is part of, if any.
+	WorkflowID string `json:"workflowID,omitempty"`
+	GetJobResponse
+}
+
+func FromJobPage(page runnable.JobPage) ListJobsResponse {
+	response := ListJobsResponse{
+		Jobs:       []ListedJob{},
+		NextCursor: page.NextCursor,
+	}
+
+	for _, job := range page.Jobs {
+		response.Jobs = append(response.Jobs, ListedJob{
+			ID:             job.ID,
+			OwnerID:        job.OwnerID,
+			Command:        job.Spec.Command,
+			Args:           job.Spec.Args,
+			Shell:          job.Spec.Shell,
+			Labels:         job.Spec.Labels,
+			Priority:       job.Spec.Priority,
+			ScheduleID:     job.Spec.ScheduleID,
+			WorkflowID:     job.Spec.WorkflowID,
+			GetJobResponse: FromJob(job),
+		})
+	}
+
+	return response
+}
+
+type GetSharesResponse struct {
+	Owners []OwnerShareResponse `json:"owners"`
+}
+
+type OwnerShareResponse struct {
+	OwnerID string `json:"ownerID"`
+	Weight  int    `json:"weight"`
+	// Fraction of the running jobs the owner is entitled to.
+	Share float64 `json:"share"`
+	// Fraction of the running jobs that are the owner's.
+	Usage   float64 `json:"usage"`
+	Running int     `json:"running"`
+	Queued  int     `json:"queued"`
+}
+
+func FromShares(shares []runnable.OwnerShare) GetSharesResponse {
+	response := GetSharesResponse{
+		Owners: []OwnerShareResponse{},
+	}
+
+	for _, share := range shares {
+		response.Owners = append(response.Owners, OwnerShareResponse{
+			OwnerID: share.OwnerID,
+			Weight:  share.Weight,
+			Share:   share.Share,
+			Usage:   share.Usage,
+			Running: share.Running,
+			Queued:  share.Queued,
+		})
+	}
+
+	return response
+}
+
+type CreateScheduleRequest struct {
+	// When jobs are started, eg "0 * * * *" for the start of every hour, in the server's time zone.
+	// 5 fields, or 6 with seconds first, or one of @hourly, @daily, @weekly, @monthly and @yearly.
+	Cron string `json:"cron" binding:"required"`
+	// What to do when the last job has not finished by the time the next is due: skip (the default), queue or allow.
+	Overlap string `json:"overlap,omitempty"`
+	// What every job runs, which can't have a deadline.
+	Job StartJobRequest `json:"job"`
+}
+
+type CreateScheduleResponse struct {
+	ScheduleID string `json:"scheduleID"`
+}
+
+type GetScheduleResponse struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"ownerID"`
+	Cron      string    `json:"cron"`
+	Overlap   string    `json:"overlap"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args,omitempty"`
+	Shell     bool      `json:"shell,omitempty"`
+	Paused    bool      `json:"paused"`
+	CreatedAt time.Time `json:"createdAt"`
+	// When the next job is due to start, unless the schedule is paused.
+	NextRun *time.Time `json:"nextRun,omitempty"`
+	// The job started most recently, and when.
+	LastJobID string     `json:"lastJobID,omitempty"`
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	// Why a job was not started the last time one was due.
+	LastError string `json:"lastError,omitempty"`
+}
+
+func FromSchedule(schedule runnable.Schedule) GetScheduleResponse {
+	response := GetScheduleResponse{
+		ID:        schedule.ID,
+		OwnerID:   schedule.OwnerID,
+		Cron:      schedule.Spec.Cron,
+		Overlap:   string(schedule.Spec.Overlap),
+		Command:   schedule.Spec.Job.Command,
+		Args:      schedule.Spec.Job.Args,
+		Shell:     schedule.Spec.Job.Shell,
+		Paused:    schedule.Paused,
+		CreatedAt: schedule.CreatedAt,
+		LastJobID: schedule.LastJobID,
+		LastError: schedule.LastError,
+	}
+	if !schedule.NextRun.IsZero() {
+		response.NextRun = &schedule.NextRun
+	}
+	if !schedule.LastRun.IsZero() {
+		response.LastRun = &schedule.LastRun
+	}
+	return response
+}
+
+type ListSchedulesResponse struct {
+	Schedules []GetScheduleResponse `json:"schedules"`
+}
+
+func FromSchedules(schedules []runnable.Schedule) ListSchedulesResponse {
+	response := ListSchedulesResponse{
+		Schedules: []GetScheduleResponse{},
+	}
+
+	for _, schedule := range schedules {
+		response.Schedules = append(response.Schedules, FromSchedule(schedule))
+	}
+
+	return response
+}
+
+type SubmitWorkflowRequest struct {
+	Jobs []WorkflowJobRequest `json:"jobs" binding:"required"`
+	// What to do when a job does not succeed: skip (the default) skips the jobs that depend on it,
+	// cancel stops the whole workflow.
+	OnFailure string `json:"onFailure,omitempty"`
+}
+
+// A job in a workflow, with the same fields as a job started on its own.
+type WorkflowJobRequest struct {
+	// Unique within the workflow.
+	Name string `json:"name"`
+	// Names of the jobs that have to complete successfully before this one is started.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	StartJobRequest
+}
+
+type SubmitWorkflowResponse struct {
+	WorkflowID string `json:"workflowID"`
+}
+
+type GetWorkflowResponse struct {
+	ID        string     `json:"id"`
+	OwnerID   string     `json:"ownerID"`
+	State     string     `json:"state"`
+	OnFailure string     `json:"onFailure"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+	// In the order they were submitted.
+	Jobs []WorkflowJobResponse `json:"jobs"`
+}
+
+type WorkflowJobResponse struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+	State     string   `json:"state"`
+	// Empty until the job is started.
+	JobID string `json:"jobID,omitempty"`
+	// Why the job could not be started, did not succeed, or was skipped.
+	Error string `json:"error,omitempty"`
+}
+
+func FromWorkflow(workflow runnable.Workflow) GetWorkflowResponse {
+	response := GetWorkflowResponse{
+		ID:        workflow.ID,
+		OwnerID:   workflow.OwnerID,
+		State:     string(workflow.State),
+		OnFailure: string(workflow.Spec.OnFailure),
+		CreatedAt: workflow.CreatedAt,
+		Jobs:      []WorkflowJobResponse{},
+	}
+	if !workflow.EndTime.IsZero() {
+		response.EndTime = &workflow.EndTime
+	}
+
+	for i, node := range workflow.Nodes {
+		response.Jobs = append(response.Jobs, WorkflowJobResponse{
+			Name:      node.Name,
+			DependsOn: workflow.Spec.Nodes[i].DependsOn,
+			State:     string(node.State),
+			JobID:     node.JobID,
+			Error:     node.Error,
+		})
+	}
+
+	return response
+}
+
+type ListWorkflowsResponse struct {
+	Workflows []GetWorkflowResponse `json:"workflows"`
+}
+
+func FromWorkflows(workflows []runnable.Workflow) ListWorkflowsResponse {
+	response := ListWorkflowsResponse{
+		Workflows: []GetWorkflowResponse{},
+	}
+
+	for _, workflow := range workflows {
+		response.Workflows = append(response.Workflows, FromWorkflow(workflow))
+	}
+
+	return response
 }
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+type IdentitySource string
+
+// Fields of a client cert that its identity can be taken from.
+const (
+	// The subject's common name.
+	IdentityCommonName IdentitySource = "cn"
+	// A URI SAN with the spiffe scheme, eg spiffe://example.org/team/alice, which is the identity as a whole.
+	IdentitySPIFFE IdentitySource = "spiffe"
+	// An email address SAN.
+	IdentityEmail IdentitySource = "email"
+)
+
+type GroupSource string
+
+// Fields of a client cert's subject whose every value is a group the client is in.
+const (
+	GroupsFromOU GroupSource = "ou"
+	GroupsFromO  GroupSource = "o"
+)
+
+// How the principal of a client is taken from its cert.
+type IdentityConfig struct {
+	// Tried in order, the first one the cert has is the client's identity. Just the CN if empty.
+	Sources []IdentitySource
+	// Only SPIFFE IDs in this trust domain (eg example.org) are taken, any if empty.
+	SPIFFETrustDomain string
+	// No groups if empty.
+	Groups []GroupSource
+}
+
+func (config IdentityConfig) Validate() error {
+	for _, source := range config.Sources {
+		switch source {
+		case IdentityCommonName, IdentitySPIFFE, IdentityEmail:
+		default:
+			return fmt.Errorf("Unknown identity source %q, must be cn, spiffe or email", source)
+		}
+	}
+
+	for _, source := range config.Groups {
+		switch source {
+		case GroupsFromOU, GroupsFromO:
+		default:
+			return fmt.Errorf("Unknown group source %q, must be ou or o", source)
+		}
+	}
+
+	return nil
+}
+
+// Returns EUNAUTHORIZED if the cert has none of the fields the identity can be taken from,
+// or its CN is taken, has an @ or : in it and the identity can also be a SPIFFE ID or email.
+func (config IdentityConfig) Principal(cert *x509.Certificate) (runnable.Principal, error) {
+	sources := config.Sources
+	if len(sources) == 0 {
+		sources = []IdentitySource{IdentityCommonName}
+	}
+
+	var principal runnable.Principal
+	for _, source := range sources {
+		principal.ID = config.identity(cert, source)
+		if principal.ID == "" {
+			continue
+		}
+
+		// a CN like spiffe://example.org/team/alice or alice@example.org would pass for another source's identity
+		if source == IdentityCommonName && config.hasOtherSources() && strings.ContainsAny(principal.ID, "@:") {
+			return runnable.Principal{}, &runnable.Error{
+				Code:    runnable.EUNAUTHORIZED,
+				Op:      "IdentityConfig.Principal",
+				Message: fmt.Sprintf("CN %q of cert %X looks like a SPIFFE ID or email, which it cannot be the identity of.", principal.ID, cert.SerialNumber),
+			}
+		}
+		break
+	}
+
+	if principal.ID == "" {
+		return principal, &runnable.Error{
+			Code:    runnable.EUNAUTHORIZED,
+			Op:      "IdentityConfig.Principal",
+			Message: fmt.Sprintf("No identity found in cert %X.", cert.SerialNumber),
+		}
+	}
+
+	for _, source := range config.Groups {
+		switch source {
+		case GroupsFromOU:
+			principal.Groups = append(principal.Groups, cert.Subject.OrganizationalUnit...)
+		case GroupsFromO:
+			principal.Groups = append(principal.Groups, cert.Subject.Organization...)
+		}
+	}
+
+	return principal, nil
+}
+
+// Returns whether identities can be taken from other fields than the CN.
+func (config IdentityConfig) hasOtherSources() bool {
+	for _, source := range config.Sources {
+		if source != IdentityCommonName {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the identity the source gives, empty if the cert does not have it.
+func (config IdentityConfig) identity(cert *x509.Certificate, source IdentitySource) string {
+	switch source {
+	case IdentityCommonName:
+		return cert.Subject.CommonName
+	case IdentitySPIFFE:
+		for _, uri := range cert.URIs {
+			if uri.Scheme != "spiffe" || uri.Host == "" {
+				continue
+			}
+			if config.SPIFFETrustDomain == "" || uri.Host == config.SPIFFETrustDomain {
+				return uri.String()
+			}
+		}
+	case IdentityEmail:
+		if len(cert.EmailAddresses) != 0 {
+			return cert.EmailAddresses[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package server_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ambardhesi/runnable/internal/server"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestPrincipal(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/team/alice")
+	other, _ := url.Parse("spiffe://other.org/team/mallory")
+	cert := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName:         "alice",
+			Organization:       []string{"runnable"},
+			OrganizationalUnit: []string{"sre", "oncall"},
+		},
+		URIs:           []*url.URL{other, spiffe},
+		EmailAddresses: []string{"alice@example.org"},
+	}
+
+	tests := []struct {
+		config   server.IdentityConfig
+		expected runnable.Principal
+	}{
+		{server.IdentityConfig{}, runnable.Principal{ID: "alice"}},
+		{
+			server.IdentityConfig{Sources: []server.IdentitySource{server.IdentitySPIFFE}, SPIFFETrustDomain: "example.org"},
+			runnable.Principal{ID: "spiffe://example.org/team/alice"},
+		},
+		{
+			server.IdentityConfig{Sources: []server.IdentitySource{server.IdentityEmail, server.IdentityCommonName}},
+			runnable.Principal{ID: "alice@example.org"},
+		},
+		{
+			server.IdentityConfig{Groups: []server.GroupSource{server.GroupsFromOU, server.GroupsFromO}},
+			runnable.Principal{ID: "alice", Groups: []string{"sre", "oncall", "runnable"}},
+		},
+	}
+
+	for i, test := range tests {
+		principal, err := test.config.Principal(cert)
+		if err != nil || !reflect.DeepEqual(principal, test.expected) {
+			t.Errorf("%v: expected %+v, got %+v %v", i, test.expected, principal, err)
+		}
+	}
+}
+
+func TestPrincipalImpersonation(t *testing.T) {
+	config := server.IdentityConfig{
+		Sources:           []server.IdentitySource{server.IdentitySPIFFE, server.IdentityEmail, server.IdentityCommonName},
+		SPIFFETrustDomain: "example.org",
+	}
+
+	// a cert with no SANs, whose CN is someone else's SPIFFE ID or email
+	for _, cn := range []string{"spiffe://example.org/team/alice", "alice@example.org"} {
+		cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: cn}}
+		principal, err := config.Principal(cert)
+		if runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+			t.Errorf("expected CN %q to be rejected, got %+v %v", cn, principal, err)
+		}
+	}
+
+	// with only the CN to go on, there is nothing else it can pass for
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "alice@example.org"}}
+	for _, config := range []server.IdentityConfig{{}, {Sources: []server.IdentitySource{server.IdentityCommonName}}} {
+		if principal, err := config.Principal(cert); err != nil || principal.ID != "alice@example.org" {
+			t.Errorf("expected the CN to be the identity with sources %v, got %+v %v", config.Sources, principal, err)
+		}
+	}
+}
+
+func TestPrincipalWithoutIdentity(t *testing.T) {
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1), EmailAddresses: []string{"alice@example.org"}}
+
+	// falls back to the email, when the cert has no CN
+	config := server.IdentityConfig{Sources: []server.IdentitySource{server.IdentityCommonName, server.IdentityEmail}}
+	if principal, err := config.Principal(cert); err != nil || principal.ID != "alice@example.org" {
+		t.Errorf("expected the email to be the identity, got %+v %v", principal, err)
+	}
+
+	_, err := server.IdentityConfig{}.Principal(cert)
+	if runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected a cert without a CN to have no identity, got %v", err)
+	}
+
+	config = server.IdentityConfig{Sources: []server.IdentitySource{"dns"}}
+	if config.Validate() == nil {
+		t.Errorf("expected an unknown identity source to be invalid")
+	}
+}
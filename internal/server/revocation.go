@@ -0,0 +1,191 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+// Certificates revoked by the CRL files, which can be reloaded while the server is running.
+type RevocationList struct {
+	crlFiles   []string
+	caCertFile string
+	// whether certs are still accepted once their issuer's CRL is past its next update
+	allowStale bool
+	// serial numbers of the revoked certificates, by raw issuer
+	revoked map[string]map[string]bool
+	// when a newer CRL is due, by raw issuer
+	nextUpdates map[string]time.Time
+	lock        sync.RWMutex
+}
+
+// Loads the CRL files, which have to be signed by one of the CAs in caCertFile.
+// Certs whose issuer's CRL is past its next update are rejected, unless allowStale is set.
+func NewRevocationList(crlFiles []string, caCertFile string, allowStale bool) (*RevocationList, error) {
+	rl := &RevocationList{
+		crlFiles:   crlFiles,
+		caCertFile: caCertFile,
+		allowStale: allowStale,
+	}
+
+	err := rl.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return rl, nil
+}
+
+// Reads the CRL files again. The ones loaded before are kept if any of them can't be read.
+func (rl *RevocationList) Reload() error {
+	cas, err := readCerts(rl.caCertFile)
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[string]map[string]bool)
+	nextUpdates := make(map[string]time.Time)
+	for _, crlFile := range rl.crlFiles {
+		crl, err := readCRL(crlFile, cas)
+		if err != nil {
+			return err
+		}
+
+		if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(time.Now()) {
+			log.Printf("CRL %v is past its next update\n", crlFile)
+		}
+
+		issuer := string(crl.RawIssuer)
+		if revoked[issuer] == nil {
+			revoked[issuer] = make(map[string]bool)
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			revoked[issuer][entry.SerialNumber.String()] = true
+		}
+
+		// the soonest, if the issuer has more than one CRL
+		if next, ok := nextUpdates[issuer]; !ok || crl.NextUpdate.Before(next) {
+			nextUpdates[issuer] = crl.NextUpdate
+		}
+	}
+
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	rl.revoked = revoked
+	rl.nextUpdates = nextUpdates
+	return nil
+}
+
+// Reloads the CRL files every interval, until stop is closed.
+func (rl *RevocationList) Refresh(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := rl.Reload()
+			if err != nil {
+				log.Printf("Failed to reload CRLs %v\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (rl *RevocationList) Revoked(cert *x509.Certificate) bool {
+	rl.lock.RLock()
+	defer rl.lock.RUnlock()
+
+	return rl.revoked[string(cert.RawIssuer)][cert.SerialNumber.String()]
+}
+
+// Returns whether the CRL of the cert's issuer is past its next update, so it may have been revoked since.
+func (rl *RevocationList) Stale(cert *x509.Certificate, now time.Time) bool {
+	rl.lock.RLock()
+	defer rl.lock.RUnlock()
+
+	next, ok := rl.nextUpdates[string(cert.RawIssuer)]
+	return ok && !next.IsZero() && next.Before(now)
+}
+
+// Meant for tls.Config.VerifyPeerCertificate, rejects chains with any revoked certificate in them, and
+// unless stale CRLs are allowed, any certificate whose issuer's CRL is out of date.
+// Only called once the chains have been verified against the CA.
+func (rl *RevocationList) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	now := time.Now()
+	for _, chain := range verifiedChains {
+		for _, cert := range chain {
+			if rl.Revoked(cert) {
+				log.Printf("Rejected revoked certificate serial %X CN %v\n", cert.SerialNumber, cert.Subject.CommonName)
+				return fmt.Errorf("certificate %X has been revoked", cert.SerialNumber)
+			}
+			if !rl.allowStale && rl.Stale(cert, now) {
+				log.Printf("Rejected certificate serial %X CN %v, the CRL of its issuer is out of date\n",
+					cert.SerialNumber, cert.Subject.CommonName)
+				return fmt.Errorf("the CRL of certificate %X's issuer is past its next update", cert.SerialNumber)
+			}
+		}
+	}
+	return nil
+}
+
+func readCRL(crlFile string, cas []*x509.Certificate) (*x509.RevocationList, error) {
+	b, err := ioutil.ReadFile(crlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// PEM or DER
+	if block, _ := pem.Decode(b); block != nil && block.Type == "X509 CRL" {
+		b = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse CRL %v %v", crlFile, err)
+	}
+
+	for _, ca := range cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			return crl, nil
+		}
+	}
+	return nil, fmt.Errorf("CRL %v is not signed by the CA", crlFile)
+}
+
+func readCerts(certFile string) ([]*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No certs found in %v", certFile)
+	}
+	return certs, nil
+}
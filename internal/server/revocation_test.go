@@ -0,0 +1,170 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/internal/server"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) issue(t *testing.T, serial int64, cn string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return cert, key
+}
+
+func (ca testCA) writeCRL(t *testing.T, path string, serials ...int64) {
+	ca.writeCRLUntil(t, path, time.Now().Add(time.Hour), serials...)
+}
+
+// Writes a CRL that is due to be replaced at nextUpdate.
+func (ca testCA) writeCRLUntil(t *testing.T, path string, nextUpdate time.Time, serials ...int64) {
+	var revoked []x509.RevocationListEntry
+	for _, serial := range serials {
+		revoked = append(revoked, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(time.Now().UnixNano()),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: revoked,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600)
+}
+
+func writeCert(path string, cert *x509.Certificate) {
+	ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600)
+}
+
+func TestRevocationList(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	caFile := filepath.Join(dir, "ca-cert.pem")
+	writeCert(caFile, ca.cert)
+
+	alice, _ := ca.issue(t, 2, "alice")
+	bob, _ := ca.issue(t, 3, "bob")
+	crlFile := filepath.Join(dir, "ca.crl")
+	ca.writeCRL(t, crlFile, 3)
+
+	rl, err := server.NewRevocationList([]string{crlFile}, caFile, false)
+	if err != nil {
+		t.Fatalf("expected CRL to load, got %v", err)
+	}
+
+	if rl.Revoked(alice) || !rl.Revoked(bob) {
+		t.Errorf("expected only bob's cert to be revoked")
+	}
+	if err := rl.VerifyPeerCertificate(nil, [][]*x509.Certificate{{bob, ca.cert}}); err == nil {
+		t.Errorf("expected bob's chain to be rejected")
+	}
+	if err := rl.VerifyPeerCertificate(nil, [][]*x509.Certificate{{alice, ca.cert}}); err != nil {
+		t.Errorf("expected alice's chain to be accepted, got %v", err)
+	}
+
+	// reloading picks up the new CRL
+	ca.writeCRL(t, crlFile, 2)
+	if err := rl.Reload(); err != nil {
+		t.Fatalf("expected CRL to reload, got %v", err)
+	}
+	if !rl.Revoked(alice) || rl.Revoked(bob) {
+		t.Errorf("expected only alice's cert to be revoked after reloading")
+	}
+
+	// a CRL from another CA is not trusted, and the old one is kept
+	newTestCA(t, "other").writeCRL(t, crlFile, 3)
+	if err := rl.Reload(); err == nil {
+		t.Errorf("expected a CRL signed by another CA to be rejected")
+	}
+	if !rl.Revoked(alice) {
+		t.Errorf("expected the last good CRL to be kept")
+	}
+}
+
+func TestStaleRevocationList(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "ca")
+	caFile := filepath.Join(dir, "ca-cert.pem")
+	writeCert(caFile, ca.cert)
+
+	alice, _ := ca.issue(t, 2, "alice")
+	crlFile := filepath.Join(dir, "ca.crl")
+	ca.writeCRLUntil(t, crlFile, time.Now().Add(-time.Minute))
+
+	// alice may have been revoked since the CRL was due to be replaced
+	rl, err := server.NewRevocationList([]string{crlFile}, caFile, false)
+	if err != nil {
+		t.Fatalf("expected CRL to load, got %v", err)
+	}
+	if err := rl.VerifyPeerCertificate(nil, [][]*x509.Certificate{{alice, ca.cert}}); err == nil {
+		t.Errorf("expected alice's chain to be rejected while the CRL is out of date")
+	}
+
+	// until a newer one is loaded
+	ca.writeCRL(t, crlFile)
+	if err := rl.Reload(); err != nil {
+		t.Fatalf("expected CRL to reload, got %v", err)
+	}
+	if err := rl.VerifyPeerCertificate(nil, [][]*x509.Certificate{{alice, ca.cert}}); err != nil {
+		t.Errorf("expected alice's chain to be accepted, got %v", err)
+	}
+
+	// unless the config says otherwise
+	ca.writeCRLUntil(t, crlFile, time.Now().Add(-time.Minute))
+	rl, _ = server.NewRevocationList([]string{crlFile}, caFile, true)
+	if err := rl.VerifyPeerCertificate(nil, [][]*x509.Certificate{{alice, ca.cert}}); err != nil {
+		t.Errorf("expected alice's chain to be accepted with stale CRLs allowed, got %v", err)
+	}
+}
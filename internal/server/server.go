@@ -9,15 +9,19 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"net/http"
 
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/cgroup"
 	"github.com/ambardhesi/runnable/pkg/job"
 	"github.com/ambardhesi/runnable/pkg/repository"
 	"github.com/ambardhesi/runnable/pkg/runnable"
+	"github.com/ambardhesi/runnable/pkg/schedule"
+	"github.com/ambardhesi/runnable/pkg/workflow"
 	"github.com/gin-gonic/gin"
 )
 
@@ -28,47 +32,174 @@ type Config struct {
 	KeyFilePath    string
 	CaCertFilePath string
 	TestMode       bool
+	// Root cgroup v2 dir under which every job gets its own cgroup, eg /sys/fs/cgroup/runnable.
+	// Resource limits are disabled if empty.
+	CgroupRoot string
+	// Limits applied to jobs that do not set their own.
+	DefaultLimits runnable.ResourceLimits
+	// Highest limits a job may ask for, a zero field means no maximum.
+	MaxLimits runnable.ResourceLimits
+	// Users and groups jobs may ask to run as. Root is never allowed.
+	AllowedUIDs []uint32
+	AllowedGIDs []uint32
+	// Who jobs that do not ask for a user run as, the server's own user if nil. Can't be root.
+	// A server running as root rejects jobs that do not ask for a user if nil.
+	DefaultCredential *runnable.Credential
+	// How many jobs may run at once, overall and per owner, and how the running jobs are shared
+	// between owners. Jobs past that wait in a queue.
+	Scheduler job.SchedulerConfig
+	// JSON file with the roles of the clients that may get at other owners' jobs, see auth.Policy.
+	// Clients only get at their own jobs if empty.
+	PolicyFile string
+	// Longest a job may run for, and the timeout of jobs that do not set one. No limit if zero.
+	MaxRuntime time.Duration
+	// File jobs are recorded in, so that they and their logs survive restarts.
+	// Jobs are only kept in memory, and logs are deleted on shutdown, if empty.
+	JobStoreFile string
+	// File schedules are recorded in, so that they survive restarts. Schedules are only kept in memory if empty.
+	ScheduleStoreFile string
+	// CRL files, signed by the CA, of client certs that are no longer accepted. Reloaded every
+	// CRLRefreshInterval, if not zero, and when the server gets SIGHUP.
+	CRLFiles           []string
+	CRLRefreshInterval time.Duration
+	// Accepts client certs whose issuer's CRL is past its next update. They are rejected until a newer CRL
+	// is loaded if not set, as they may have been revoked since.
+	AllowStaleCRLs bool
+	// How often the cert, key and CA files are checked for changes, which new connections then use.
+	// They are only reloaded when the server gets SIGHUP if zero.
+	CertWatchInterval time.Duration
+	// Which fields of client certs their identity and groups are taken from. Just the CN if empty.
+	Identity IdentityConfig
 }
 
 type Server struct {
 	config Config
 	js     runnable.JobService
+	ss     *schedule.ScheduleService
+	ws     runnable.WorkflowService
 	lfs    runnable.LogFileService
-	server http.Server
+	// the cert, key and CA that new connections use
+	tlsFiles *TLSFiles
+	// nil if there are no CRL files
+	rl      *RevocationList
+	server  *http.Server
+	stopped chan struct{}
+	// Stop only shuts the server down the first time it is called
+	stopOnce sync.Once
 }
 
 func NewServer(config Config) (*Server, error) {
-	db := repository.NewInMemoryDB()
 	lfs, err := repository.NewLocalFileSystem(config.LogDir)
 	if err != nil {
 		return nil, err
 	}
 
-	js := job.NewJobService(db, lfs)
+	var db runnable.JobStoreService
+	if config.JobStoreFile != "" {
+		db, err = repository.NewFileDB(config.JobStoreFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		db = repository.NewInMemoryDB()
+	}
+
+	err = config.Identity.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if cred := config.DefaultCredential; cred != nil && (cred.UID == 0 || cred.GID == 0) {
+		return nil, errors.New("Jobs can't run as root by default")
+	}
+
+	// left as a nil interface (not a nil *cgroup.Manager) when cgroups are disabled
+	var cgs runnable.CgroupService
+	if config.CgroupRoot != "" {
+		cgs, err = cgroup.NewManager(config.CgroupRoot)
+		if err != nil {
+			return nil, err
+		}
+	} else if !config.DefaultLimits.IsZero() || !config.MaxLimits.IsZero() {
+		return nil, errors.New("Resource limits need a cgroup root to be configured")
+	}
+
+	var authorizer runnable.Authorizer
+	if config.PolicyFile != "" {
+		policy, err := auth.LoadPolicy(config.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		authorizer = auth.NewPolicyAuthorizer(policy)
+	}
+
+	js := job.NewJobService(db, lfs, cgs, config.Scheduler, authorizer)
+
+	var sdb runnable.ScheduleStoreService
+	if config.ScheduleStoreFile != "" {
+		sdb, err = repository.NewFileScheduleDB(config.ScheduleStoreFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sdb = repository.NewInMemoryScheduleDB()
+	}
+
+	ss, err := schedule.NewScheduleService(sdb, js, authorizer)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsFiles, err := LoadTLSFiles(config.CertFilePath, config.KeyFilePath, config.CaCertFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var rl *RevocationList
+	if len(config.CRLFiles) != 0 {
+		rl, err = NewRevocationList(config.CRLFiles, config.CaCertFilePath, config.AllowStaleCRLs)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &Server{
-		config: config,
-		js:     js,
-		lfs:    lfs,
+		config:   config,
+		js:       js,
+		ss:       ss,
+		ws:       workflow.NewWorkflowService(js, authorizer),
+		lfs:      lfs,
+		tlsFiles: tlsFiles,
+		rl:       rl,
+		stopped:  make(chan struct{}),
 	}, nil
 }
 
-// extracts the client ID from the client cert CN and sets it as the key
-func certMiddleware(ctx *gin.Context) {
+// extracts the client's principal from the client cert and sets it as the key
+func (s *Server) certMiddleware(ctx *gin.Context) {
 	tls := ctx.Request.TLS
-	if len(tls.PeerCertificates) == 0 {
+	if tls == nil || len(tls.PeerCertificates) == 0 {
 		log.Printf("No cert found in request")
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": errors.New("No cert found")})
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No cert found"})
 		return
 	}
 
-	clientCert := tls.PeerCertificates[0]
-	ownerID := clientCert.Subject.CommonName
+	principal, err := s.config.Identity.Principal(tls.PeerCertificates[0])
+	if err != nil {
+		log.Printf("Rejected request %v\n", err)
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 
-	ctx.Set("ownerID", ownerID)
+	ctx.Set("principal", principal)
 	ctx.Next()
 }
 
+// Only called once certMiddleware has set the principal.
+func principalOf(ctx *gin.Context) runnable.Principal {
+	return ctx.MustGet("principal").(runnable.Principal)
+}
+
 func (s *Server) Start() {
 	if !s.config.TestMode {
 		// Log HTTP server output to console.
@@ -78,40 +209,74 @@ func (s *Server) Start() {
 	}
 
 	router := gin.Default()
-	router.Use(certMiddleware)
+	router.Use(s.certMiddleware)
 
 	// Wire up routes
 	router.POST("/job", s.StartJob)
 	router.GET("job/:id", s.GetJob)
 	router.POST("/job/:id/stop", s.StopJob)
+	router.POST("/job/:id/pause", s.PauseJob)
+	router.POST("/job/:id/resume", s.ResumeJob)
 	router.GET("/job/:id/logs", s.GetJobLogs)
+	router.GET("/jobs", s.ListJobs)
+	router.GET("/admin/shares", s.GetShares)
+	router.POST("/schedules", s.CreateSchedule)
+	router.GET("/schedules", s.ListSchedules)
+	router.GET("/schedules/:id", s.GetSchedule)
+	router.DELETE("/schedules/:id", s.DeleteSchedule)
+	router.POST("/schedules/:id/pause", s.PauseSchedule)
+	router.POST("/schedules/:id/resume", s.ResumeSchedule)
+	router.POST("/workflows", s.SubmitWorkflow)
+	router.GET("/workflows", s.ListWorkflows)
+	router.GET("/workflows/:id", s.GetWorkflow)
+	router.POST("/workflows/:id/stop", s.StopWorkflow)
 
 	s.monitorTerminationSignal()
+	s.monitorReloadSignal()
 
-	tlsConfig, err := GetTLSConfig(s.config.CertFilePath, s.config.KeyFilePath, s.config.CaCertFilePath)
-	if err != nil {
-		log.Printf("Failed to get TLSConfig %v\n", tlsConfig)
-		os.Exit(1)
+	if s.rl != nil && s.config.CRLRefreshInterval > 0 {
+		go s.rl.Refresh(s.config.CRLRefreshInterval, s.stopped)
+	}
+
+	if s.config.CertWatchInterval > 0 {
+		go s.tlsFiles.Watch(s.config.CertWatchInterval, s.stopped)
 	}
 
 	// Start server on port provided in config
-	server := http.Server{
+	server := &http.Server{
 		Addr:      "localhost:" + strconv.Itoa(s.config.Port),
 		Handler:   router,
-		TLSConfig: tlsConfig,
+		TLSConfig: GetTLSConfig(s.tlsFiles, s.rl),
 	}
 	s.server = server
 
-	err = server.ListenAndServeTLS("", "")
+	err := server.ListenAndServeTLS("", "")
 	if err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
 }
 
+// Can be called more than once, eg by a signal and by whoever started the server, only the first call does anything.
 func (s *Server) Stop() {
-	err := s.lfs.DeleteAllLogFiles()
-	if err != nil {
-		log.Printf("Failed to delete all log files before shutting down %v\n", err)
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Server) stop() {
+	// no new jobs are started while shutting down
+	s.ss.Close()
+	close(s.stopped)
+
+	// the logs of recorded jobs are kept, so they can still be read after a restart
+	if s.config.JobStoreFile == "" {
+		err := s.lfs.DeleteAllLogFiles()
+		if err != nil {
+			log.Printf("Failed to delete all log files before shutting down %v\n", err)
+		}
+	}
+
+	// server was never started
+	if s.server == nil {
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -122,7 +287,7 @@ func (s *Server) Stop() {
 }
 
 func (s *Server) monitorTerminationSignal() {
-	killChan := make(chan os.Signal)
+	killChan := make(chan os.Signal, 1)
 	signal.Notify(killChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
@@ -133,6 +298,42 @@ func (s *Server) monitorTerminationSignal() {
 	}()
 }
 
+// reloads the cert, key, CA and CRL files on SIGHUP, until the server is stopped
+func (s *Server) monitorReloadSignal() {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hupChan)
+		for {
+			select {
+			case <-hupChan:
+				s.reload()
+			case <-s.stopped:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Server) reload() {
+	err := s.tlsFiles.Reload()
+	if err != nil {
+		log.Printf("Failed to reload server cert %v\n", err)
+	} else {
+		log.Printf("Reloaded server cert and CA\n")
+	}
+
+	if s.rl != nil {
+		err := s.rl.Reload()
+		if err != nil {
+			log.Printf("Failed to reload CRLs %v\n", err)
+		} else {
+			log.Printf("Reloaded CRLs\n")
+		}
+	}
+}
+
 func (s *Server) StartJob(ctx *gin.Context) {
 	var request StartJobRequest
 	err := ctx.ShouldBindJSON(&request)
@@ -142,10 +343,20 @@ func (s *Server) StartJob(ctx *gin.Context) {
 		return
 	}
 
-	ownerID := ctx.GetString("ownerID")
-	cmd := strings.Split(request.Command, " ")
-	jobID, err := s.js.Start(ownerID, cmd[0], cmd[1:]...)
+	spec, err := s.jobSpec(request)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	principal := principalOf(ctx)
+	jobID, err := s.js.Start(principal, spec)
 
+	if err != nil && jobID != "" {
+		// the job failed to start, but can still be looked at
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error(), "jobID": jobID})
+		return
+	}
 	if err != nil {
 		writeError(ctx, err)
 		return
@@ -156,11 +367,68 @@ func (s *Server) StartJob(ctx *gin.Context) {
 	})
 }
 
+// Turns the request into a job spec, with the server's defaults filled in and its limits checked.
+func (s *Server) jobSpec(request StartJobRequest) (runnable.JobSpec, error) {
+	limits := request.Limits().WithDefaults(s.config.DefaultLimits)
+	err := limits.Validate(s.config.MaxLimits)
+	if err != nil {
+		return runnable.JobSpec{}, err
+	}
+
+	cred, err := request.Credential()
+	if err != nil {
+		return runnable.JobSpec{}, err
+	}
+
+	if cred == nil {
+		cred = s.config.DefaultCredential
+	} else if err := cred.Validate(s.config.AllowedUIDs, s.config.AllowedGIDs); err != nil {
+		return runnable.JobSpec{}, err
+	}
+
+	// jobs would otherwise run as root, and get around their limits
+	if cred == nil && os.Geteuid() == 0 {
+		return runnable.JobSpec{}, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "Server.jobSpec",
+			Message: "Jobs have to ask for a user to run as, the server does not run them as root.",
+		}
+	}
+
+	timeout, deadline, err := request.Runtime(s.config.MaxRuntime)
+	if err != nil {
+		return runnable.JobSpec{}, err
+	}
+
+	restart, err := request.RestartPolicy()
+	if err != nil {
+		return runnable.JobSpec{}, err
+	}
+
+	return runnable.JobSpec{
+		Command:     request.Command,
+		Args:        request.Args,
+		Shell:       request.Shell,
+		Limits:      limits,
+		Isolated:    request.Isolated,
+		HostNetwork: request.HostNetwork,
+		Labels:      request.Labels,
+		Env:         request.Env,
+		ClearEnv:    request.ClearEnv,
+		WorkingDir:  request.WorkingDir,
+		Credential:  cred,
+		Timeout:     timeout,
+		Deadline:    deadline,
+		Priority:    request.Priority,
+		Restart:     restart,
+	}, nil
+}
+
 func (s *Server) GetJob(ctx *gin.Context) {
 	jobID := ctx.Param("id")
-	ownerID := ctx.GetString("ownerID")
+	principal := principalOf(ctx)
 
-	job, err := s.js.Get(ownerID, jobID)
+	job, err := s.js.Get(principal, jobID)
 
 	if err != nil {
 		writeError(ctx, err)
@@ -170,11 +438,83 @@ func (s *Server) GetJob(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, FromJob(job))
 }
 
+func (s *Server) ListJobs(ctx *gin.Context) {
+	principal := principalOf(ctx)
+
+	var request ListJobsRequest
+	err := ctx.ShouldBindQuery(&request)
+	if err != nil {
+		log.Printf("failed to create list jobs request %v\n", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := request.ListOptions()
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	page, err := s.js.List(principal, opts)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FromJobPage(page))
+}
+
 func (s *Server) StopJob(ctx *gin.Context) {
 	jobID := ctx.Param("id")
-	ownerID := ctx.GetString("ownerID")
+	principal := principalOf(ctx)
+
+	// the body is optional, the job is killed straight away without one
+	var request StopJobRequest
+	if ctx.Request.ContentLength != 0 {
+		err := ctx.ShouldBindJSON(&request)
+		if err != nil {
+			log.Printf("failed to create stop job request %v\n", err)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	opts, err := request.StopOptions()
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	err = s.js.Stop(principal, jobID, opts)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "")
+}
+
+func (s *Server) PauseJob(ctx *gin.Context) {
+	jobID := ctx.Param("id")
+	principal := principalOf(ctx)
 
-	err := s.js.Stop(ownerID, jobID)
+	err := s.js.Pause(principal, jobID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "")
+}
+
+func (s *Server) ResumeJob(ctx *gin.Context) {
+	jobID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	err := s.js.Resume(principal, jobID)
 
 	if err != nil {
 		writeError(ctx, err)
@@ -186,26 +526,270 @@ func (s *Server) StopJob(ctx *gin.Context) {
 
 func (s *Server) GetJobLogs(ctx *gin.Context) {
 	jobID := ctx.Param("id")
-	ownerID := ctx.GetString("ownerID")
+	principal := principalOf(ctx)
+
+	var request GetLogsRequest
+	err := ctx.ShouldBindQuery(&request)
+	if err != nil {
+		log.Printf("failed to create get logs request %v\n", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := request.LogOptions()
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	logs, err := s.js.GetLogs(principal, jobID, opts)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	defer logs.Close()
+
+	// the request context is cancelled when the client goes away, which stops a follow
+	go func() {
+		<-ctx.Request.Context().Done()
+		logs.Close()
+	}()
+
+	ctx.Header("Content-Type", "text/plain; charset=utf-8")
+	ctx.Status(http.StatusOK)
+
+	// flushed after every read, so a follower sees new logs as soon as they are written
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, err := ctx.Writer.Write(buf[:n]); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("failed to read logs for job %v %v\n", jobID, err)
+			}
+			return
+		}
+	}
+}
+
+func (s *Server) CreateSchedule(ctx *gin.Context) {
+	var request CreateScheduleRequest
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		log.Printf("failed to create create schedule request %v\n", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	logs, err := s.js.GetLogs(ownerID, jobID)
+	spec, err := s.jobSpec(request.Job)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	principal := principalOf(ctx)
+	scheduleID, err := s.ss.Create(principal, runnable.ScheduleSpec{
+		Cron:    request.Cron,
+		Overlap: runnable.OverlapPolicy(request.Overlap),
+		Job:     spec,
+	})
 
 	if err != nil {
 		writeError(ctx, err)
 		return
 	}
 
-	ctx.String(http.StatusOK, *logs)
+	ctx.JSON(http.StatusOK, CreateScheduleResponse{
+		ScheduleID: scheduleID,
+	})
+}
+
+func (s *Server) GetSchedule(ctx *gin.Context) {
+	scheduleID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	schedule, err := s.ss.Get(principal, scheduleID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FromSchedule(schedule))
+}
+
+func (s *Server) ListSchedules(ctx *gin.Context) {
+	principal := principalOf(ctx)
+
+	// another owner's, or * for every owner's
+	schedules, err := s.ss.List(principal, ctx.Query("owner"))
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FromSchedules(schedules))
+}
+
+func (s *Server) DeleteSchedule(ctx *gin.Context) {
+	scheduleID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	err := s.ss.Delete(principal, scheduleID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "")
+}
+
+func (s *Server) PauseSchedule(ctx *gin.Context) {
+	scheduleID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	err := s.ss.Pause(principal, scheduleID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "")
+}
+
+func (s *Server) ResumeSchedule(ctx *gin.Context) {
+	scheduleID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	err := s.ss.Resume(principal, scheduleID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "")
+}
+
+func (s *Server) SubmitWorkflow(ctx *gin.Context) {
+	var request SubmitWorkflowRequest
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		log.Printf("failed to create submit workflow request %v\n", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spec := runnable.WorkflowSpec{
+		OnFailure: runnable.FailurePolicy(request.OnFailure),
+	}
+	for _, node := range request.Jobs {
+		jobSpec, err := s.jobSpec(node.StartJobRequest)
+		if err != nil {
+			writeError(ctx, err)
+			return
+		}
+
+		spec.Nodes = append(spec.Nodes, runnable.WorkflowNodeSpec{
+			Name:      node.Name,
+			DependsOn: node.DependsOn,
+			Job:       jobSpec,
+		})
+	}
+
+	principal := principalOf(ctx)
+	workflowID, err := s.ws.Submit(principal, spec)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, SubmitWorkflowResponse{
+		WorkflowID: workflowID,
+	})
+}
+
+func (s *Server) GetWorkflow(ctx *gin.Context) {
+	workflowID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	workflow, err := s.ws.Get(principal, workflowID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FromWorkflow(workflow))
+}
+
+func (s *Server) ListWorkflows(ctx *gin.Context) {
+	principal := principalOf(ctx)
+
+	// another owner's, or * for every owner's
+	workflows, err := s.ws.List(principal, ctx.Query("owner"))
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FromWorkflows(workflows))
+}
+
+func (s *Server) StopWorkflow(ctx *gin.Context) {
+	workflowID := ctx.Param("id")
+	principal := principalOf(ctx)
+
+	err := s.ws.Stop(principal, workflowID)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.String(http.StatusOK, "")
+}
+
+func (s *Server) GetShares(ctx *gin.Context) {
+	principal := principalOf(ctx)
+
+	shares, err := s.js.Shares(principal)
+
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FromShares(shares))
 }
 
 func writeError(ctx *gin.Context, err error) {
-	if runnable.ErrorCode(err) == runnable.ENOTFOUND {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-	} else if runnable.ErrorCode(err) == runnable.EINVALID {
-		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
-	} else if runnable.ErrorCode(err) == runnable.EUNAUTHORIZED {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-	} else {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+}
+
+func errorStatus(err error) int {
+	switch runnable.ErrorCode(err) {
+	case runnable.ENOTFOUND:
+		return http.StatusNotFound
+	case runnable.EINVALID:
+		return http.StatusUnprocessableEntity
+	case runnable.EUNAUTHORIZED:
+		return http.StatusUnauthorized
+	case runnable.EUNAVAILABLE:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
 	}
 }
@@ -2,12 +2,16 @@ package server_test
 
 import (
 	"log"
+	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ambardhesi/runnable/internal/server"
+	"github.com/ambardhesi/runnable/pkg/runnable"
 )
 
 func startServer(port int) *server.Server {
@@ -19,6 +23,10 @@ func startServer(port int) *server.Server {
 		CaCertFilePath: "../../certs/ca-cert.pem",
 		TestMode:       true,
 	}
+	// jobs are not run as root
+	if os.Geteuid() == 0 {
+		config.DefaultCredential = &runnable.Credential{UID: 65534, GID: 65534}
+	}
 
 	s, err := server.NewServer(config)
 	if err != nil {
@@ -29,6 +37,17 @@ func startServer(port int) *server.Server {
 	go func() {
 		s.Start()
 	}()
+
+	// wait for the server to start listening before any client calls it
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", "localhost:"+strconv.Itoa(port))
+		if err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
 	return s
 }
 
@@ -41,6 +60,7 @@ func TestUnauthorized(t *testing.T) {
 		"--ca", "../../certs/ca-cert.pem",
 		"--cert", "../../certs/alice-cert.pem",
 		"--key", "../../certs/alice-key.pem",
+		"--server", "https://localhost:8080",
 		"start", "echo", "hello", "world",
 	}
 
@@ -58,6 +78,7 @@ func TestUnauthorized(t *testing.T) {
 		"--ca", "../../certs/ca-cert.pem",
 		"--cert", "../../certs/bob-cert.pem",
 		"--key", "../../certs/bob-key.pem",
+		"--server", "https://localhost:8080",
 		"get", jobID,
 	}
 
@@ -81,6 +102,7 @@ func TestHappyPath(t *testing.T) {
 		"--ca", "../../certs/ca-cert.pem",
 		"--cert", "../../certs/alice-cert.pem",
 		"--key", "../../certs/alice-key.pem",
+		"--server", "https://localhost:8081",
 		"start", "echo", "hello", "world",
 	}
 
@@ -98,6 +120,7 @@ func TestHappyPath(t *testing.T) {
 		"--ca", "../../certs/ca-cert.pem",
 		"--cert", "../../certs/alice-cert.pem",
 		"--key", "../../certs/alice-key.pem",
+		"--server", "https://localhost:8081",
 		"get", jobID,
 	}
 
@@ -111,3 +134,11 @@ func TestHappyPath(t *testing.T) {
 		t.Errorf("expected job to be completed")
 	}
 }
+
+func TestStopTwice(t *testing.T) {
+	s := startServer(8082)
+
+	// eg once on SIGTERM, and once more by whoever started the server
+	s.Stop()
+	s.Stop()
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// Lets some users start some commands, eg
+// {"groups": ["ops"], "commands": ["/usr/bin/rsync"], "args": ["-a", "/srv/[a-z]+/"], "workingDirs": ["/srv/*"]}
+// A job is allowed if any of the rules that apply to its owner allows all of it.
+type CommandRule struct {
+	// Who the rule applies to, by certificate identity, or * for everyone.
+	Users []string `json:"users,omitempty"`
+	// Groups the rule applies to the members of, by Policy.Groups or by their certificate.
+	Groups []string `json:"groups,omitempty"`
+	// Absolute path patterns (as in path.Match) of the commands jobs may run, or * for any. Jobs have to give
+	// their command by its absolute path too, as what a bare name runs depends on the PATH it is looked up in.
+	Commands []string `json:"commands,omitempty"`
+	// Regular expressions every argument has to match the whole of one of. Any arguments are allowed if empty.
+	Args []string `json:"args,omitempty"`
+	// Whether jobs may be shell scripts. Scripts are checked against Args like arguments, not against Commands,
+	// so they can run anything unless Args limits them.
+	Shell bool `json:"shell,omitempty"`
+	// Path patterns of the environment variables jobs may set, or * for any. Jobs may not set any if empty.
+	Env []string `json:"env,omitempty"`
+	// Whether jobs may run without the server's environment.
+	ClearEnv bool `json:"clearEnv,omitempty"`
+	// Path patterns of the directories jobs may run in. Jobs may only run in the server's if empty.
+	WorkingDirs []string `json:"workingDirs,omitempty"`
+}
+
+func (rule CommandRule) validate() error {
+	if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+		return fmt.Errorf("it applies to no users or groups")
+	}
+
+	for _, pattern := range rule.Commands {
+		if pattern != "*" && !path.IsAbs(pattern) {
+			return fmt.Errorf("command %q is not an absolute path", pattern)
+		}
+	}
+
+	patterns := append(append(append([]string(nil), rule.Commands...), rule.Env...), rule.WorkingDirs...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", pattern)
+		}
+	}
+
+	for _, pattern := range rule.Args {
+		if _, err := compileArg(pattern); err != nil {
+			return fmt.Errorf("bad argument pattern %q %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Argument patterns have to match the whole argument.
+func compileArg(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// Returns EUNAUTHORIZED if no rule lets the user run the job's command at all,
+// and EINVALID if some do, but none with the job's arguments, environment or working directory.
+func (authorizer *PolicyAuthorizer) AuthorizeJob(principal runnable.Principal, spec runnable.JobSpec) error {
+	op := "PolicyAuthorizer.AuthorizeJob"
+	if len(authorizer.policy.Commands) == 0 {
+		return nil
+	}
+
+	var rules []CommandRule
+	for _, rule := range authorizer.policy.Commands {
+		if authorizer.appliesTo(rule, principal) && authorizer.allowsCommand(rule, spec) {
+			rules = append(rules, rule)
+		}
+	}
+
+	if len(rules) == 0 {
+		message := fmt.Sprintf("User is unauthorized, %v may not run %q.", principal.ID, spec.Command)
+		if !path.IsAbs(spec.Command) {
+			message = fmt.Sprintf("User is unauthorized, %v may not run %q, commands have to be given by their absolute path.",
+				principal.ID, spec.Command)
+		}
+		if spec.Shell {
+			message = fmt.Sprintf("User is unauthorized, %v may not run shell scripts.", principal.ID)
+		}
+		return &runnable.Error{
+			Code:    runnable.EUNAUTHORIZED,
+			Op:      op,
+			Message: message,
+		}
+	}
+
+	// the reason the first rule for the command gives is as good as any
+	var reason string
+	for _, rule := range rules {
+		ruleReason := authorizer.check(rule, spec)
+		if ruleReason == "" {
+			return nil
+		}
+		if reason == "" {
+			reason = ruleReason
+		}
+	}
+
+	return &runnable.Error{
+		Code:    runnable.EINVALID,
+		Op:      op,
+		Message: fmt.Sprintf("Job is not allowed by the command policy, %v.", reason),
+	}
+}
+
+func (authorizer *PolicyAuthorizer) appliesTo(rule CommandRule, principal runnable.Principal) bool {
+	for _, user := range rule.Users {
+		if user == principal.ID || user == "*" {
+			return true
+		}
+	}
+
+	for _, group := range rule.Groups {
+		if authorizer.inGroup(principal, group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (authorizer *PolicyAuthorizer) allowsCommand(rule CommandRule, spec runnable.JobSpec) bool {
+	if spec.Shell {
+		return rule.Shell
+	}
+
+	// a bare name could be found anywhere on the job's PATH, so only a rule for any command allows it
+	if !path.IsAbs(spec.Command) {
+		return matchAny(rule.Commands, "*")
+	}
+
+	// cleaned, so that eg /usr/bin/../../tmp/x does not pass for a command under /usr/bin
+	return matchAny(rule.Commands, path.Clean(spec.Command))
+}
+
+// Returns why the rule does not allow the job, empty if it does.
+func (authorizer *PolicyAuthorizer) check(rule CommandRule, spec runnable.JobSpec) string {
+	args := spec.Args
+	if spec.Shell {
+		args = append([]string{spec.Command}, args...)
+	}
+
+	if len(rule.Args) != 0 {
+		for _, arg := range args {
+			if !authorizer.matchArg(rule, arg) {
+				return fmt.Sprintf("argument %q is not allowed", arg)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(spec.Env))
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !matchAny(rule.Env, key) {
+			return fmt.Sprintf("environment variable %v may not be set", key)
+		}
+	}
+
+	if spec.ClearEnv && !rule.ClearEnv {
+		return "the environment may not be cleared"
+	}
+
+	// cleaned, so that eg /srv/../etc does not pass for a directory under /srv
+	if spec.WorkingDir != "" && !matchAny(rule.WorkingDirs, path.Clean(spec.WorkingDir)) {
+		return fmt.Sprintf("it may not run in %v", spec.WorkingDir)
+	}
+
+	return ""
+}
+
+func (authorizer *PolicyAuthorizer) matchArg(rule CommandRule, arg string) bool {
+	for _, pattern := range rule.Args {
+		if re, ok := authorizer.args[pattern]; ok && re.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether any of the names match any of the patterns, * matching everything.
+func matchAny(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if matched, _ := path.Match(pattern, name); matched || pattern == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestAuthorizeJob(t *testing.T) {
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{
+		Groups: map[string][]string{"ops": {"olivia"}},
+		Commands: []auth.CommandRule{
+			{Users: []string{"*"}, Commands: []string{"/bin/echo"}},
+			{
+				Groups:      []string{"ops"},
+				Commands:    []string{"/usr/bin/*"},
+				Args:        []string{"-[a-z]+", "/srv/[a-z]+"},
+				Env:         []string{"LC_*"},
+				WorkingDirs: []string{"/srv/*"},
+			},
+			{Users: []string{"sam"}, Shell: true, Args: []string{"echo [a-z ]*"}},
+		},
+	})
+
+	tests := []struct {
+		userID string
+		spec   runnable.JobSpec
+		code   string
+	}{
+		{"alice", runnable.JobSpec{Command: "/bin/echo", Args: []string{"anything goes"}}, ""},
+		// what a bare name runs depends on the PATH, so it is not taken to be /bin/echo
+		{"alice", runnable.JobSpec{Command: "echo"}, runnable.EUNAUTHORIZED},
+		{"alice", runnable.JobSpec{Command: "/bin/../tmp/echo"}, runnable.EUNAUTHORIZED},
+		{"alice", runnable.JobSpec{Command: "/usr/bin/env"}, runnable.EUNAUTHORIZED},
+		{"alice", runnable.JobSpec{Command: "/bin/echo", Env: map[string]string{"LC_ALL": "C"}}, runnable.EINVALID},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", Args: []string{"-i", "/srv/data"}}, ""},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", Args: []string{"/etc/passwd"}}, runnable.EINVALID},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", Env: map[string]string{"LC_ALL": "C"}}, ""},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", Env: map[string]string{"PATH": "/tmp"}}, runnable.EINVALID},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", ClearEnv: true}, runnable.EINVALID},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", WorkingDir: "/srv/data"}, ""},
+		{"olivia", runnable.JobSpec{Command: "/usr/bin/env", WorkingDir: "/srv/../etc"}, runnable.EINVALID},
+		{"olivia", runnable.JobSpec{Command: "echo hi", Shell: true}, runnable.EUNAUTHORIZED},
+		{"sam", runnable.JobSpec{Command: "echo hi", Shell: true}, ""},
+		{"sam", runnable.JobSpec{Command: "rm -rf /", Shell: true}, runnable.EINVALID},
+	}
+
+	for i, test := range tests {
+		err := authorizer.AuthorizeJob(runnable.Principal{ID: test.userID}, test.spec)
+		if code := runnable.ErrorCode(err); code != test.code {
+			t.Errorf("%v: expected %q, got %v", i, test.code, err)
+		}
+	}
+
+	// in ops by their certificate rather than the policy
+	err := authorizer.AuthorizeJob(runnable.Principal{ID: "pat", Groups: []string{"ops"}}, runnable.JobSpec{Command: "/usr/bin/env"})
+	if err != nil {
+		t.Errorf("expected a member of ops to run /usr/bin/env, got %v", err)
+	}
+}
+
+func TestAuthorizeJobWithoutCommandRules(t *testing.T) {
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{})
+
+	err := authorizer.AuthorizeJob(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "rm", Args: []string{"-rf", "/"}})
+	if err != nil {
+		t.Errorf("expected every job to be allowed without command rules, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+type Role string
+
+// Roles grant users access to the jobs of other owners. Every user has full access to their own jobs.
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleViewer   Role = "viewer"
+)
+
+// What every role may do to other owners' jobs, unless the policy says otherwise.
+var DefaultRoles = map[Role][]runnable.Action{
+	RoleAdmin:    {runnable.ActionRead, runnable.ActionLogs, runnable.ActionStop, runnable.ActionAdmin},
+	RoleOperator: {runnable.ActionRead, runnable.ActionLogs, runnable.ActionStop},
+	RoleViewer:   {runnable.ActionRead},
+}
+
+// How denials describe the actions.
+var verbs = map[runnable.Action]string{
+	runnable.ActionRead: "see",
+	runnable.ActionLogs: "get the logs of",
+	runnable.ActionStop: "stop, pause or resume",
+}
+
+// Who has which role, and what they may run, as read from a policy file, eg
+// {"users": {"alice": "admin", "carol": "viewer"}, "groupRoles": {"sre": "operator"}, "roles": {"viewer": ["read", "logs"]}}
+type Policy struct {
+	// The role of every user that has one, by certificate identity.
+	Users map[string]Role `json:"users"`
+	// The role of the members of groups, whether they are in them by Groups or by their certificate.
+	// Users with more than one role get what any of them grants.
+	GroupRoles map[string]Role `json:"groupRoles,omitempty"`
+	// Replaces the actions DefaultRoles grants to any of the roles.
+	Roles map[Role][]runnable.Action `json:"roles,omitempty"`
+	// The members of every group, by certificate identity, on top of the groups in their certificates.
+	Groups map[string][]string `json:"groups,omitempty"`
+	// What jobs users may start, see CommandRule. Users may start any job if there are none.
+	Commands []CommandRule `json:"commands,omitempty"`
+}
+
+// Reads the policy from a JSON file.
+// Returns EINVALID if it names roles or actions that do not exist.
+func LoadPolicy(filePath string) (Policy, error) {
+	var policy Policy
+
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return policy, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "auth.LoadPolicy",
+			Message: "Failed to read policy file.",
+			Err:     err,
+		}
+	}
+
+	err = json.Unmarshal(b, &policy)
+	if err != nil {
+		return policy, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "auth.LoadPolicy",
+			Message: fmt.Sprintf("Invalid policy file %v.", filePath),
+			Err:     err,
+		}
+	}
+
+	return policy, policy.Validate()
+}
+
+// Returns EINVALID if the policy names roles or actions that do not exist.
+func (policy Policy) Validate() error {
+	invalid := func(format string, a ...interface{}) error {
+		return &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "Policy.Validate",
+			Message: fmt.Sprintf(format, a...),
+		}
+	}
+
+	for user, role := range policy.Users {
+		if _, ok := DefaultRoles[role]; !ok {
+			return invalid("User %v has unknown role %q, must be admin, operator or viewer.", user, role)
+		}
+	}
+
+	for group, role := range policy.GroupRoles {
+		if _, ok := DefaultRoles[role]; !ok {
+			return invalid("Group %v has unknown role %q, must be admin, operator or viewer.", group, role)
+		}
+	}
+
+	for role, actions := range policy.Roles {
+		if _, ok := DefaultRoles[role]; !ok {
+			return invalid("Unknown role %q, must be admin, operator or viewer.", role)
+		}
+
+		for _, action := range actions {
+			switch action {
+			case runnable.ActionRead, runnable.ActionLogs, runnable.ActionStop, runnable.ActionAdmin:
+			default:
+				return invalid("Role %v has unknown action %q, must be read, logs, stop or admin.", role, action)
+			}
+		}
+	}
+
+	for i, rule := range policy.Commands {
+		err := rule.validate()
+		if err != nil {
+			return invalid("Command rule %v is invalid, %v.", i, err)
+		}
+	}
+
+	return nil
+}
+
+// implementation of runnable.Authorizer
+// Users may do anything to their own jobs, and what their role grants to everyone else's.
+type PolicyAuthorizer struct {
+	policy Policy
+	// the compiled argument patterns of every command rule
+	args map[string]*regexp.Regexp
+}
+
+// The zero value of Policy only lets users at their own jobs, and lets them start any.
+// The policy should be valid, argument patterns that are not match no arguments.
+func NewPolicyAuthorizer(policy Policy) *PolicyAuthorizer {
+	args := make(map[string]*regexp.Regexp)
+	for _, rule := range policy.Commands {
+		for _, pattern := range rule.Args {
+			re, err := compileArg(pattern)
+			if err == nil {
+				args[pattern] = re
+			}
+		}
+	}
+
+	return &PolicyAuthorizer{
+		policy: policy,
+		args:   args,
+	}
+}
+
+func (authorizer *PolicyAuthorizer) Authorize(principal runnable.Principal, action runnable.Action, ownerID string) error {
+	if ownerID == principal.ID && action != runnable.ActionAdmin {
+		return nil
+	}
+
+	roles := authorizer.roles(principal)
+	for _, role := range roles {
+		if authorizer.grants(role, action) {
+			return nil
+		}
+	}
+
+	userID := principal.ID
+	var reason string
+	switch {
+	case action == runnable.ActionStart:
+		reason = fmt.Sprintf("%v may only start jobs of their own", userID)
+	case action == runnable.ActionAdmin:
+		reason = fmt.Sprintf("%v is not an admin", userID)
+	case ownerID == runnable.AnyOwner:
+		reason = fmt.Sprintf("%v may not %v other owners' jobs", userID, verbs[action])
+	default:
+		reason = fmt.Sprintf("%v may not %v %v's jobs", userID, verbs[action], ownerID)
+	}
+	if len(roles) != 0 && action != runnable.ActionAdmin {
+		names := make([]string, len(roles))
+		for i, role := range roles {
+			names[i] = string(role)
+		}
+		reason += fmt.Sprintf(" as a %v", strings.Join(names, " and "))
+	}
+
+	return &runnable.Error{
+		Code:    runnable.EUNAUTHORIZED,
+		Op:      "PolicyAuthorizer.Authorize",
+		Message: fmt.Sprintf("User is unauthorized, %v.", reason),
+	}
+}
+
+// Returns the roles of the principal, and of the groups they are in, without duplicates.
+func (authorizer *PolicyAuthorizer) roles(principal runnable.Principal) []Role {
+	var roles []Role
+	add := func(role Role) {
+		for _, r := range roles {
+			if r == role {
+				return
+			}
+		}
+		roles = append(roles, role)
+	}
+
+	if role, ok := authorizer.policy.Users[principal.ID]; ok {
+		add(role)
+	}
+
+	groups := make([]string, 0, len(authorizer.policy.GroupRoles))
+	for group := range authorizer.policy.GroupRoles {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		if authorizer.inGroup(principal, group) {
+			add(authorizer.policy.GroupRoles[group])
+		}
+	}
+
+	return roles
+}
+
+// Returns whether the principal's certificate, or the policy, puts them in the group.
+func (authorizer *PolicyAuthorizer) inGroup(principal runnable.Principal, group string) bool {
+	for _, g := range principal.Groups {
+		if g == group {
+			return true
+		}
+	}
+
+	for _, member := range authorizer.policy.Groups[group] {
+		if member == principal.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (authorizer *PolicyAuthorizer) grants(role Role, action runnable.Action) bool {
+	actions, ok := authorizer.policy.Roles[role]
+	if !ok {
+		actions = DefaultRoles[role]
+	}
+
+	// starting jobs for someone else is never allowed
+	for _, a := range actions {
+		if a == action && action != runnable.ActionStart {
+			return true
+		}
+	}
+	return false
+}
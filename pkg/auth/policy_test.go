@@ -0,0 +1,118 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestAuthorize(t *testing.T) {
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{
+		Users: map[string]auth.Role{
+			"ada":    auth.RoleAdmin,
+			"olivia": auth.RoleOperator,
+			"victor": auth.RoleViewer,
+			"lena":   auth.RoleViewer,
+		},
+		Roles: map[auth.Role][]runnable.Action{
+			auth.RoleViewer: {runnable.ActionRead, runnable.ActionLogs},
+		},
+	})
+
+	tests := []struct {
+		userID  string
+		action  runnable.Action
+		ownerID string
+		allowed bool
+	}{
+		{"alice", runnable.ActionStart, "alice", true},
+		{"alice", runnable.ActionStop, "alice", true},
+		{"alice", runnable.ActionRead, "bob", false},
+		{"alice", runnable.ActionAdmin, runnable.AnyOwner, false},
+		{"ada", runnable.ActionAdmin, runnable.AnyOwner, true},
+		{"ada", runnable.ActionStart, "bob", false},
+		{"olivia", runnable.ActionStop, "bob", true},
+		{"olivia", runnable.ActionRead, runnable.AnyOwner, true},
+		{"olivia", runnable.ActionAdmin, runnable.AnyOwner, false},
+		{"victor", runnable.ActionRead, "bob", true},
+		{"victor", runnable.ActionStop, "bob", false},
+		// the policy lets viewers get logs as well
+		{"lena", runnable.ActionLogs, "bob", true},
+	}
+
+	for _, test := range tests {
+		err := authorizer.Authorize(runnable.Principal{ID: test.userID}, test.action, test.ownerID)
+		if test.allowed && err != nil {
+			t.Errorf("expected %v to %v jobs of %v, got %v", test.userID, test.action, test.ownerID, err)
+		}
+		if !test.allowed && runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+			t.Errorf("expected %v not to %v jobs of %v, got %v", test.userID, test.action, test.ownerID, err)
+		}
+	}
+}
+
+func TestAuthorizeGroups(t *testing.T) {
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{
+		Users:      map[string]auth.Role{"victor": auth.RoleViewer},
+		GroupRoles: map[string]auth.Role{"sre": auth.RoleOperator, "audit": auth.RoleViewer},
+		Groups:     map[string][]string{"sre": {"sam"}},
+	})
+
+	// in the group by their certificate
+	err := authorizer.Authorize(runnable.Principal{ID: "olivia", Groups: []string{"sre"}}, runnable.ActionStop, "bob")
+	if err != nil {
+		t.Errorf("expected a member of sre to stop jobs, got %v", err)
+	}
+
+	// in the group by the policy
+	err = authorizer.Authorize(runnable.Principal{ID: "sam"}, runnable.ActionStop, "bob")
+	if err != nil {
+		t.Errorf("expected a member of sre to stop jobs, got %v", err)
+	}
+
+	// the roles of the user and their groups add up
+	err = authorizer.Authorize(runnable.Principal{ID: "victor", Groups: []string{"audit"}}, runnable.ActionStop, "bob")
+	if runnable.ErrorCode(err) != runnable.EUNAUTHORIZED || !strings.Contains(err.Error(), "as a viewer.") {
+		t.Errorf("expected victor not to stop jobs as a viewer, got %v", err)
+	}
+	err = authorizer.Authorize(runnable.Principal{ID: "victor", Groups: []string{"audit", "sre"}}, runnable.ActionStop, "bob")
+	if err != nil {
+		t.Errorf("expected victor to stop jobs as a member of sre, got %v", err)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		policy string
+		valid  bool
+	}{
+		{`{"users": {"alice": "admin"}, "roles": {"viewer": ["read", "logs"]}}`, true},
+		{`{"users": {"alice": "root"}}`, false},
+		{`{"groupRoles": {"sre": "root"}}`, false},
+		{`{"roles": {"viewer": ["delete"]}}`, false},
+		{`{"users": `, false},
+		{`{"commands": [{"users": ["*"], "commands": ["/bin/echo"], "args": ["[a-z"]}]}`, false},
+		{`{"commands": [{"commands": ["/bin/echo"]}]}`, false},
+		{`{"commands": [{"users": ["*"], "commands": ["echo"]}]}`, false},
+		{`{"commands": [{"users": ["*"], "commands": ["*"]}]}`, true},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, "policy.json")
+		os.WriteFile(path, []byte(test.policy), 0600)
+
+		_, err := auth.LoadPolicy(path)
+		if test.valid && err != nil {
+			t.Errorf("%v: expected policy to load, got %v", i, err)
+		}
+		if !test.valid && runnable.ErrorCode(err) != runnable.EINVALID {
+			t.Errorf("%v: expected policy to be invalid, got %v", i, err)
+		}
+	}
+}
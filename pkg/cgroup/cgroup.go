@@ -0,0 +1,307 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+const (
+	dirPermission = 0755
+
+	// cpu.max quotas are given per period, in microseconds
+	cpuPeriod = 100000
+
+	// controllers that need to be enabled for job cgroups
+	controllers = "+cpu +memory +io"
+)
+
+// implementation of runnable.CgroupService, using cgroup v2
+// Every job gets its own cgroup, as a child of the root cgroup.
+type Manager struct {
+	root string
+}
+
+// Creates the root cgroup for jobs (eg /sys/fs/cgroup/runnable) and enables the
+// cpu, memory and io controllers for its children.
+// The parent of root must already have those controllers enabled.
+func NewManager(root string) (*Manager, error) {
+	op := "cgroup.NewManager"
+
+	err := os.MkdirAll(root, dirPermission)
+	if err != nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to create root cgroup.",
+			Err:     err,
+		}
+	}
+
+	err = writeFile(root, "cgroup.subtree_control", controllers)
+	if err != nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to enable cgroup controllers.",
+			Err:     err,
+		}
+	}
+
+	return &Manager{
+		root: root,
+	}, nil
+}
+
+func (m *Manager) Create(jobID string, limits runnable.ResourceLimits) (runnable.Cgroup, error) {
+	op := "Manager.Create"
+
+	cg := &Cgroup{
+		path: path.Join(m.root, jobID),
+	}
+
+	err := os.Mkdir(cg.path, dirPermission)
+	if err != nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to create cgroup.",
+			Err:     err,
+		}
+	}
+
+	err = cg.setLimits(limits)
+	if err != nil {
+		_ = cg.Delete()
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to set cgroup limits.",
+			Err:     err,
+		}
+	}
+
+	return cg, nil
+}
+
+// implementation of runnable.Cgroup
+type Cgroup struct {
+	path string
+}
+
+func (cg *Cgroup) setLimits(limits runnable.ResourceLimits) error {
+	if limits.CPUMillis != 0 {
+		quota := limits.CPUMillis * cpuPeriod / 1000
+		err := writeFile(cg.path, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod))
+		if err != nil {
+			return err
+		}
+	}
+
+	if limits.MemoryBytes != 0 {
+		err := writeFile(cg.path, "memory.max", strconv.FormatInt(limits.MemoryBytes, 10))
+		if err != nil {
+			return err
+		}
+	}
+
+	// io.max takes a single device per write
+	for _, io := range limits.IO {
+		err := writeFile(cg.path, "io.max", formatIOLimit(io))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formats an IO limit as an io.max line, eg "8:0 rbps=1048576 wiops=120"
+func formatIOLimit(io runnable.IOLimit) string {
+	fields := []string{io.Device}
+
+	for _, l := range []struct {
+		key   string
+		value int64
+	}{
+		{"rbps", io.ReadBPS},
+		{"wbps", io.WriteBPS},
+		{"riops", io.ReadIOPS},
+		{"wiops", io.WriteIOPS},
+	} {
+		if l.value != 0 {
+			fields = append(fields, fmt.Sprintf("%v=%d", l.key, l.value))
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func (cg *Cgroup) Open() (*os.File, error) {
+	return os.Open(cg.path)
+}
+
+func (cg *Cgroup) Kill() error {
+	// cgroup.kill only exists from linux 5.14
+	err := writeFile(cg.path, "cgroup.kill", "1")
+	if err == nil {
+		return nil
+	}
+
+	pids, err := cg.pids()
+	if err != nil {
+		return err
+	}
+
+	for _, pid := range pids {
+		err = syscall.Kill(pid, syscall.SIGKILL)
+		if err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cg *Cgroup) Freeze() error {
+	return writeFile(cg.path, "cgroup.freeze", "1")
+}
+
+func (cg *Cgroup) Thaw() error {
+	return writeFile(cg.path, "cgroup.freeze", "0")
+}
+
+func (cg *Cgroup) Stats() (runnable.CgroupStats, error) {
+	var stats runnable.CgroupStats
+
+	b, err := os.ReadFile(path.Join(cg.path, "memory.current"))
+	if err != nil {
+		return stats, err
+	}
+	stats.MemoryBytes, err = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return stats, err
+	}
+
+	// only there from Linux 5.19
+	b, err = os.ReadFile(path.Join(cg.path, "memory.peak"))
+	if err == nil {
+		stats.PeakMemoryBytes, err = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return stats, err
+	}
+
+	cpu, err := readKeyedFile(cg.path, "cpu.stat")
+	if err != nil {
+		return stats, err
+	}
+	for _, line := range cpu {
+		if line[0] == "usage_usec" && len(line) == 2 {
+			usec, err := strconv.ParseInt(line[1], 10, 64)
+			if err != nil {
+				return stats, err
+			}
+			stats.CPUUsage = time.Duration(usec) * time.Microsecond
+		}
+	}
+
+	// a line for every device, eg "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0"
+	io, err := readKeyedFile(cg.path, "io.stat")
+	if err != nil {
+		return stats, err
+	}
+	for _, line := range io {
+		for _, field := range line[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || (kv[0] != "rbytes" && kv[0] != "wbytes") {
+				continue
+			}
+
+			value, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return stats, err
+			}
+			if kv[0] == "rbytes" {
+				stats.IOReadBytes += value
+			} else {
+				stats.IOWriteBytes += value
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+func (cg *Cgroup) OOMKills() (int64, error) {
+	events, err := readKeyedFile(cg.path, "memory.events")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range events {
+		if line[0] == "oom_kill" && len(line) == 2 {
+			return strconv.ParseInt(line[1], 10, 64)
+		}
+	}
+	return 0, nil
+}
+
+func (cg *Cgroup) Empty() (bool, error) {
+	pids, err := cg.pids()
+	if err != nil {
+		return false, err
+	}
+
+	return len(pids) == 0, nil
+}
+
+func (cg *Cgroup) pids() ([]int, error) {
+	b, err := os.ReadFile(path.Join(cg.path, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(b)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+// Deletes the cgroup. Fails if it still has processes in it.
+func (cg *Cgroup) Delete() error {
+	// cgroupfs only needs the dir removed, RemoveAll tries that first
+	return os.RemoveAll(cg.path)
+}
+
+// Returns the fields of every line of the file that is not empty.
+func readKeyedFile(dir string, name string) ([][]string, error) {
+	b, err := os.ReadFile(path.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var lines [][]string
+	for _, line := range strings.Split(string(b), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	return lines, nil
+}
+
+func writeFile(dir string, name string, content string) error {
+	return os.WriteFile(path.Join(dir, name), []byte(content), 0644)
+}
@@ -0,0 +1,197 @@
+package cgroup_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/cgroup"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// cgroupfs is just files, so a temp dir stands in for it here
+
+func readFile(t *testing.T, elem ...string) string {
+	b, err := os.ReadFile(path.Join(elem...))
+	if err != nil {
+		t.Fatalf("expected to read %v, got %v", path.Join(elem...), err)
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func TestNewManagerEnablesControllers(t *testing.T) {
+	root := path.Join(t.TempDir(), "runnable")
+
+	_, err := cgroup.NewManager(root)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	if c := readFile(t, root, "cgroup.subtree_control"); c != "+cpu +memory +io" {
+		t.Errorf("expected controllers to be enabled, got %v", c)
+	}
+}
+
+func TestCreateSetsLimits(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+
+	limits := runnable.ResourceLimits{
+		CPUMillis:   500,
+		MemoryBytes: 1 << 20,
+		IO: []runnable.IOLimit{
+			{Device: "8:0", ReadBPS: 1024, WriteIOPS: 10},
+		},
+	}
+
+	cg, err := m.Create("jobID", limits)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	if c := readFile(t, root, "jobID", "cpu.max"); c != "50000 100000" {
+		t.Errorf("expected cpu.max %v, got %v", "50000 100000", c)
+	}
+
+	if c := readFile(t, root, "jobID", "memory.max"); c != "1048576" {
+		t.Errorf("expected memory.max %v, got %v", "1048576", c)
+	}
+
+	if c := readFile(t, root, "jobID", "io.max"); c != "8:0 rbps=1024 wiops=10" {
+		t.Errorf("expected io.max %v, got %v", "8:0 rbps=1024 wiops=10", c)
+	}
+
+	dir, err := cg.Open()
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	if dir.Name() != path.Join(root, "jobID") {
+		t.Errorf("expected cgroup dir %v to be opened, got %v", path.Join(root, "jobID"), dir.Name())
+	}
+	dir.Close()
+
+	if err := cg.Delete(); err != nil {
+		t.Errorf("did not expect an error, got %v", err)
+	}
+
+	if _, err := os.Stat(path.Join(root, "jobID")); !os.IsNotExist(err) {
+		t.Errorf("expected cgroup to be deleted")
+	}
+}
+
+func TestCreateWithoutLimits(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+
+	_, err := m.Create("jobID", runnable.ResourceLimits{})
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	for _, f := range []string{"cpu.max", "memory.max", "io.max"} {
+		if _, err := os.Stat(path.Join(root, "jobID", f)); !os.IsNotExist(err) {
+			t.Errorf("expected %v to not be written", f)
+		}
+	}
+}
+
+func TestCreateExistingCgroup(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+
+	_, _ = m.Create("jobID", runnable.ResourceLimits{})
+	_, err := m.Create("jobID", runnable.ResourceLimits{})
+
+	if runnable.ErrorCode(err) != runnable.EINTERNAL {
+		t.Errorf("expected error type %v, got error %v", runnable.EINTERNAL, err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+	cg, _ := m.Create("jobID", runnable.ResourceLimits{})
+
+	// cgroup.procs always exists in a real cgroup
+	_ = os.WriteFile(path.Join(root, "jobID", "cgroup.procs"), nil, 0644)
+
+	if empty, err := cg.Empty(); err != nil || !empty {
+		t.Errorf("expected cgroup to be empty, got %v %v", empty, err)
+	}
+
+	_ = os.WriteFile(path.Join(root, "jobID", "cgroup.procs"), []byte("42\n"), 0644)
+
+	if empty, err := cg.Empty(); err != nil || empty {
+		t.Errorf("expected cgroup to not be empty, got %v %v", empty, err)
+	}
+}
+
+func TestFreezeAndThaw(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+	cg, _ := m.Create("jobID", runnable.ResourceLimits{})
+
+	if err := cg.Freeze(); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	if c := readFile(t, root, "jobID", "cgroup.freeze"); c != "1" {
+		t.Errorf("expected cgroup.freeze %v, got %v", "1", c)
+	}
+
+	if err := cg.Thaw(); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	if c := readFile(t, root, "jobID", "cgroup.freeze"); c != "0" {
+		t.Errorf("expected cgroup.freeze %v, got %v", "0", c)
+	}
+}
+
+func TestStats(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+	cg, _ := m.Create("jobID", runnable.ResourceLimits{})
+
+	for name, content := range map[string]string{
+		"memory.current": "1048576\n",
+		"memory.peak":    "2097152\n",
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n",
+		"io.stat": "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n" +
+			"8:16 rbytes=1 wbytes=2 rios=1 wios=1 dbytes=0 dios=0\n",
+	} {
+		os.WriteFile(path.Join(root, "jobID", name), []byte(content), 0644)
+	}
+
+	stats, err := cg.Stats()
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	expected := runnable.CgroupStats{
+		MemoryBytes:     1048576,
+		PeakMemoryBytes: 2097152,
+		CPUUsage:        1500 * time.Millisecond,
+		IOReadBytes:     1025,
+		IOWriteBytes:    2050,
+	}
+	if stats != expected {
+		t.Errorf("expected stats %v, got %v", expected, stats)
+	}
+}
+
+func TestOOMKills(t *testing.T) {
+	root := t.TempDir()
+	m, _ := cgroup.NewManager(root)
+	cg, _ := m.Create("jobID", runnable.ResourceLimits{})
+
+	os.WriteFile(path.Join(root, "jobID", "memory.events"), []byte("low 0\nhigh 0\nmax 12\noom 2\noom_kill 2\n"), 0644)
+
+	kills, err := cg.OOMKills()
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	if kills != 2 {
+		t.Errorf("expected %v OOM kills, got %v", 2, kills)
+	}
+}
@@ -0,0 +1,122 @@
+package job
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// Jobs are ordered by start time, and then by ID for jobs started at the same time.
+type jobPosition struct {
+	startTime time.Time
+	jobID     string
+}
+
+func positionOf(job *runnable.Job) jobPosition {
+	return jobPosition{
+		startTime: job.StoredStatus().StartTime,
+		jobID:     job.ID,
+	}
+}
+
+func (p jobPosition) before(other jobPosition) bool {
+	if !p.startTime.Equal(other.startTime) {
+		return p.startTime.Before(other.startTime)
+	}
+	return p.jobID < other.jobID
+}
+
+// Cursors are the position of the last job on a page, so a page carries on from there
+// even if jobs were started or removed in between.
+func (p jobPosition) cursor() string {
+	s := strconv.FormatInt(p.startTime.UnixNano(), 10) + "/" + p.jobID
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func parseCursor(cursor string) (jobPosition, error) {
+	invalid := &runnable.Error{
+		Code:    runnable.EINVALID,
+		Op:      "JobService.List",
+		Message: fmt.Sprintf("Invalid cursor %q.", cursor),
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return jobPosition{}, invalid
+	}
+
+	fields := strings.SplitN(string(b), "/", 2)
+	if len(fields) != 2 {
+		return jobPosition{}, invalid
+	}
+
+	nanos, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return jobPosition{}, invalid
+	}
+
+	return jobPosition{
+		startTime: time.Unix(0, nanos),
+		jobID:     fields[1],
+	}, nil
+}
+
+// Sorts the jobs, and returns the page of them that comes after the cursor in opts.
+func paginate(jobs []*runnable.Job, opts runnable.ListOptions) (runnable.JobPage, error) {
+	limit := opts.Limit
+	if limit == 0 {
+		limit = runnable.DefaultListLimit
+	}
+	if limit < 0 || limit > runnable.MaxListLimit {
+		return runnable.JobPage{}, &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "JobService.List",
+			Message: fmt.Sprintf("Limit must be between 1 and %v.", runnable.MaxListLimit),
+		}
+	}
+
+	// positions are taken once, as a running job's start time can be set while sorting
+	positions := make(map[*runnable.Job]jobPosition, len(jobs))
+	for _, job := range jobs {
+		positions[job] = positionOf(job)
+	}
+
+	// true if a comes before b in the order asked for
+	inOrder := func(a, b jobPosition) bool {
+		if opts.Ascending {
+			return a.before(b)
+		}
+		return b.before(a)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return inOrder(positions[jobs[i]], positions[jobs[j]])
+	})
+
+	if opts.Cursor != "" {
+		after, err := parseCursor(opts.Cursor)
+		if err != nil {
+			return runnable.JobPage{}, err
+		}
+
+		// the first job that comes after the cursor
+		start := sort.Search(len(jobs), func(i int) bool {
+			return inOrder(after, positions[jobs[i]])
+		})
+		jobs = jobs[start:]
+	}
+
+	var page runnable.JobPage
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+		page.NextCursor = positions[jobs[limit-1]].cursor()
+	}
+	page.Jobs = jobs
+
+	return page, nil
+}
@@ -0,0 +1,146 @@
+package job
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// Reads a job's log records as text, keeping only the records of the stream and attempt asked for.
+// When following, it waits for the job to write more once it gets to the end, like tail -f.
+// Reads return io.EOF once everything has been read (and when following, once the job or the attempt
+// has finished), or once the reader has been closed.
+type logReader struct {
+	job  *runnable.Job
+	file runnable.LogReader
+	opts runnable.LogOptions
+	// rendered text not yet returned by Read
+	pending []byte
+	// whether the next record of a stream starts a new line, which gets a timestamp
+	lineStart map[runnable.LogStream]bool
+	// index of the next record in the file
+	next      int
+	finished  bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newLogReader(job *runnable.Job, file runnable.LogReader, opts runnable.LogOptions) *logReader {
+	return &logReader{
+		job:  job,
+		file: file,
+		opts: opts,
+		lineStart: map[runnable.LogStream]bool{
+			runnable.Stdout: true,
+			runnable.Stderr: true,
+		},
+		closed: make(chan struct{}),
+	}
+}
+
+func (r *logReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		// taken before reading, so a write that lands after we hit the end still wakes us up
+		updated := r.job.LogUpdated()
+
+		record, err := r.file.ReadRecord()
+		if err == nil {
+			inAttempt, attemptOver := r.inAttempt(r.next)
+			r.next++
+			if attemptOver {
+				return 0, io.EOF
+			}
+
+			if inAttempt && (r.opts.Stream == "" || r.opts.Stream == record.Stream) {
+				r.pending = r.render(record)
+			}
+			continue
+		}
+
+		if err != io.EOF {
+			return 0, err
+		}
+
+		if _, attemptOver := r.inAttempt(r.next); attemptOver || !r.opts.Follow || r.finished {
+			return 0, io.EOF
+		}
+
+		select {
+		case <-updated:
+		case <-r.job.Done():
+			// the job wrote all of its logs before finishing, so one more pass gets whatever is left
+			r.finished = true
+		case <-r.closed:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Returns whether the record with the given index belongs to the attempt asked for, and whether the
+// attempt has finished before it. Every record belongs if no attempt was asked for.
+func (r *logReader) inAttempt(index int) (bool, bool) {
+	if r.opts.Attempt == 0 {
+		return true, false
+	}
+
+	// read afresh, as a running attempt only knows how many records it wrote once it has finished
+	attempts := r.job.StoredStatus().Attempts
+	if r.opts.Attempt > len(attempts) {
+		return false, false
+	}
+
+	attempt := attempts[r.opts.Attempt-1]
+	if index < attempt.FirstLogRecord {
+		return false, false
+	}
+
+	if !attempt.EndTime.IsZero() && index >= attempt.FirstLogRecord+attempt.LogRecords {
+		return false, true
+	}
+
+	return true, false
+}
+
+func (r *logReader) render(record runnable.LogRecord) []byte {
+	if !r.opts.Timestamps {
+		return record.Payload
+	}
+
+	timestamp := []byte(record.Time.UTC().Format(time.RFC3339Nano) + " ")
+
+	var buf bytes.Buffer
+	payload := record.Payload
+	for len(payload) > 0 {
+		if r.lineStart[record.Stream] {
+			buf.Write(timestamp)
+		}
+
+		line := payload
+		if i := bytes.IndexByte(payload, '\n'); i >= 0 {
+			line = payload[:i+1]
+		}
+		buf.Write(line)
+		payload = payload[len(line):]
+
+		r.lineStart[record.Stream] = line[len(line)-1] == '\n'
+	}
+
+	return buf.Bytes()
+}
+
+// Safe to call while a Read is waiting for more logs, and more than once.
+func (r *logReader) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		err = r.file.Close()
+	})
+	return err
+}
@@ -0,0 +1,394 @@
+package job
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// How many jobs may run, or wait to run, at once. A zero field means no limit.
+type SchedulerConfig struct {
+	MaxRunning         int
+	MaxRunningPerOwner int
+	// Jobs that would queue beyond this are rejected.
+	MaxQueued int
+	// How much of the running jobs each owner is entitled to, relative to each other.
+	// Owners not listed, or with a weight under 1, have a weight of 1.
+	Weights map[string]int
+}
+
+func (config SchedulerConfig) weight(ownerID string) int {
+	if weight := config.Weights[ownerID]; weight > 1 {
+		return weight
+	}
+	return 1
+}
+
+type queuedJob struct {
+	job *runnable.Job
+	// order the job was submitted in
+	seq uint64
+}
+
+// Starts jobs once there is a free slot for them.
+// A free slot goes to the waiting owner that has started the fewest jobs for their weight, and then to that
+// owner's job with the highest priority. Ties are started in the order they were submitted. An owner at
+// their own limit does not hold up jobs of other owners.
+type scheduler struct {
+	config         SchedulerConfig
+	running        map[string]bool
+	runningByOwner map[string]int
+	// each owner's queued jobs, in the order they are to be started
+	queues map[string][]*queuedJob
+	queued int
+	// jobs let into the queue that are still being set up
+	queuing int
+	seq     uint64
+	// Goes up by 1/weight every time one of the owner's jobs starts, the lowest is owed the next slot.
+	// Raised to that of the other waiting owners when an owner starts waiting, so owners can't save up
+	// for later by staying idle.
+	passes map[string]float64
+	lock   sync.Mutex
+}
+
+func newScheduler(config SchedulerConfig) *scheduler {
+	return &scheduler{
+		config:         config,
+		running:        make(map[string]bool),
+		runningByOwner: make(map[string]int),
+		queues:         make(map[string][]*queuedJob),
+		passes:         make(map[string]float64),
+	}
+}
+
+// Starts the job straight away if there is a free slot for it, or else queues it.
+// prepare sets the job up once it is known it will be taken, so nothing needs undoing if the queue is full.
+// The job's slot, or place in the queue, is held for it while it is set up and started without the lock.
+// Returns an error if the job could not be prepared or started, or the queue is full.
+func (s *scheduler) submit(job *runnable.Job, prepare func() error) error {
+	s.lock.Lock()
+
+	// jobs of other owners only queue when they are at their own limit, so only the owner's go first
+	runNow := s.hasSlot(job.OwnerID) && len(s.queues[job.OwnerID]) == 0
+
+	if !runNow && s.config.MaxQueued > 0 && s.queued+s.queuing >= s.config.MaxQueued {
+		s.lock.Unlock()
+		return &runnable.Error{
+			Code:    runnable.EUNAVAILABLE,
+			Op:      "JobService.Start",
+			Message: "Too many jobs are waiting to run, try again later.",
+		}
+	}
+
+	if runNow {
+		s.reserve(job)
+	} else {
+		s.queuing++
+	}
+	s.lock.Unlock()
+
+	err := prepare()
+	if err != nil {
+		s.lock.Lock()
+		if runNow {
+			s.release(job)
+		} else {
+			s.queuing--
+		}
+		next := s.takeQueued()
+		s.lock.Unlock()
+
+		s.startAll(next)
+		return err
+	}
+
+	if runNow {
+		return s.start(job)
+	}
+	return s.queue(job)
+}
+
+// Puts a prepared job in the queue.
+func (s *scheduler) queue(job *runnable.Job) error {
+	// numbered once it is in the queue, which can't wait on the job being recorded as Queued
+	err := job.SetQueued(0)
+
+	s.lock.Lock()
+	s.queuing--
+
+	// stopped in the meantime
+	if err != nil || job.StoredStatus().State != runnable.Queued {
+		s.lock.Unlock()
+		return err
+	}
+
+	s.seq++
+	s.enqueue(&queuedJob{job: job, seq: s.seq})
+	s.renumber(job.OwnerID)
+
+	// slots may have freed up while the job was being set up, with nothing in the queue to take them
+	next := s.takeQueued()
+	s.lock.Unlock()
+
+	s.startAll(next)
+	return nil
+}
+
+// Stops the job, which takes it out of the queue if it has not started yet.
+func (s *scheduler) stop(job *runnable.Job, opts runnable.StopOptions) error {
+	// a queued job that is being started as it is stopped is killed once it has started, and
+	// gives back its slot once it is done, like any other
+	err := job.Stop(opts)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.dequeue(job)
+	return nil
+}
+
+// Returns the share of every owner with running or queued jobs, by owner ID.
+func (s *scheduler) shares() []runnable.OwnerShare {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	owners := make(map[string]bool)
+	for ownerID := range s.runningByOwner {
+		owners[ownerID] = true
+	}
+	for ownerID := range s.queues {
+		owners[ownerID] = true
+	}
+
+	totalWeight := 0
+	for ownerID := range owners {
+		totalWeight += s.config.weight(ownerID)
+	}
+
+	shares := []runnable.OwnerShare{}
+	for ownerID := range owners {
+		share := runnable.OwnerShare{
+			OwnerID: ownerID,
+			Weight:  s.config.weight(ownerID),
+			Running: s.runningByOwner[ownerID],
+			Queued:  len(s.queues[ownerID]),
+		}
+		share.Share = float64(share.Weight) / float64(totalWeight)
+		if len(s.running) > 0 {
+			share.Usage = float64(share.Running) / float64(len(s.running))
+		}
+		shares = append(shares, share)
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].OwnerID < shares[j].OwnerID
+	})
+	return shares
+}
+
+func (s *scheduler) hasSlot(ownerID string) bool {
+	if s.config.MaxRunning > 0 && len(s.running) >= s.config.MaxRunning {
+		return false
+	}
+
+	return s.config.MaxRunningPerOwner == 0 || s.runningByOwner[ownerID] < s.config.MaxRunningPerOwner
+}
+
+// Holds a slot for the job, which it has until it is done.
+// Must be called with the lock held.
+func (s *scheduler) reserve(job *runnable.Job) {
+	s.running[job.ID] = true
+	s.runningByOwner[job.OwnerID]++
+	s.catchUp(job.OwnerID)
+	s.passes[job.OwnerID] += 1 / float64(s.config.weight(job.OwnerID))
+}
+
+// Gives back the job's slot, if it has one.
+// Must be called with the lock held.
+func (s *scheduler) release(job *runnable.Job) {
+	if !s.running[job.ID] {
+		return
+	}
+
+	delete(s.running, job.ID)
+	s.runningByOwner[job.OwnerID]--
+	if s.runningByOwner[job.OwnerID] == 0 {
+		delete(s.runningByOwner, job.OwnerID)
+	}
+}
+
+// Starts a job that has been given a slot, which it gives back if it can't be started.
+// Must be called without the lock held, as it waits for the job's process to be spawned.
+func (s *scheduler) start(job *runnable.Job) error {
+	err := job.Start()
+	if err != nil {
+		s.lock.Lock()
+		s.release(job)
+		next := s.takeQueued()
+		s.lock.Unlock()
+
+		s.startAll(next)
+		return err
+	}
+
+	go s.watch(job)
+	return nil
+}
+
+// Starts jobs taken from the queue.
+// Must be called without the lock held.
+func (s *scheduler) startAll(jobs []*runnable.Job) {
+	for _, job := range jobs {
+		err := s.start(job)
+		if err != nil {
+			// the job is Failed now, there is nobody to return the error to
+			log.Printf("Failed to start queued job %v %v\n", job.ID, err)
+		}
+	}
+}
+
+// Frees the job's slot once it has finished, and starts whatever can run in its place.
+func (s *scheduler) watch(job *runnable.Job) {
+	<-job.Done()
+
+	s.lock.Lock()
+	s.release(job)
+	s.dequeue(job)
+	next := s.takeQueued()
+	s.lock.Unlock()
+
+	s.startAll(next)
+}
+
+// Takes queued jobs out of the queue for as long as there are free slots for them, and holds a slot for each.
+// They are to be started with startAll once the lock has been released.
+// Must be called with the lock held.
+func (s *scheduler) takeQueued() []*runnable.Job {
+	var jobs []*runnable.Job
+	owners := make(map[string]bool)
+	for {
+		next := s.next()
+		if next == nil {
+			break
+		}
+
+		s.remove(next.job)
+		s.reserve(next.job)
+		jobs = append(jobs, next.job)
+		owners[next.job.OwnerID] = true
+	}
+
+	for ownerID := range owners {
+		s.renumber(ownerID)
+	}
+	return jobs
+}
+
+// Returns the job at the head of the queues that is due the next free slot, given the owners' passes.
+// Only owners with a free slot are considered. Returns nil if there is none.
+// Must be called with the lock held.
+func (s *scheduler) next() *queuedJob {
+	var next *queuedJob
+	for ownerID, queue := range s.queues {
+		if !s.hasSlot(ownerID) {
+			continue
+		}
+
+		if next == nil || before(queue[0], next, s.passes) {
+			next = queue[0]
+		}
+	}
+	return next
+}
+
+// Returns whether one owner's job is due a slot before another owner's.
+func before(a *queuedJob, b *queuedJob, passes map[string]float64) bool {
+	passA := passes[a.job.OwnerID]
+	passB := passes[b.job.OwnerID]
+	if passA != passB {
+		return passA < passB
+	}
+	return a.seq < b.seq
+}
+
+// Raises the owner's pass to the lowest of the waiting owners, if it is below it.
+// Must be called with the lock held.
+func (s *scheduler) catchUp(ownerID string) {
+	lowest, found := 0.0, false
+	for waiting := range s.queues {
+		if waiting == ownerID {
+			// an owner that is already waiting has been caught up
+			return
+		}
+		if pass := s.passes[waiting]; !found || pass < lowest {
+			lowest, found = pass, true
+		}
+	}
+
+	if found && s.passes[ownerID] < lowest {
+		s.passes[ownerID] = lowest
+	}
+}
+
+// Must be called with the lock held.
+func (s *scheduler) enqueue(queued *queuedJob) {
+	s.catchUp(queued.job.OwnerID)
+
+	queue := s.queues[queued.job.OwnerID]
+	priority := queued.job.Spec.Priority
+
+	// after the owner's jobs with the same priority or higher
+	i := sort.Search(len(queue), func(i int) bool {
+		return queue[i].job.Spec.Priority < priority
+	})
+
+	queue = append(queue, nil)
+	copy(queue[i+1:], queue[i:])
+	queue[i] = queued
+
+	s.queues[queued.job.OwnerID] = queue
+	s.queued++
+}
+
+// Takes the job out of the queue, if it is in it.
+// Must be called with the lock held.
+func (s *scheduler) dequeue(job *runnable.Job) {
+	if s.remove(job) {
+		s.renumber(job.OwnerID)
+	}
+}
+
+// Must be called with the lock held.
+func (s *scheduler) remove(job *runnable.Job) bool {
+	queue := s.queues[job.OwnerID]
+	for i, queued := range queue {
+		if queued.job != job {
+			continue
+		}
+
+		if len(queue) == 1 {
+			delete(s.queues, job.OwnerID)
+		} else {
+			s.queues[job.OwnerID] = append(queue[:i], queue[i+1:]...)
+		}
+		s.queued--
+		return true
+	}
+	return false
+}
+
+// Numbers the owner's queued jobs in the order they are to be started.
+// Other owners' jobs are left alone, their place among them depends on the owners' passes and changes as
+// jobs are started.
+// Must be called with the lock held.
+func (s *scheduler) renumber(ownerID string) {
+	for i, queued := range s.queues[ownerID] {
+		// only fails if the job is not queued any more, which takes it out of the queue
+		_ = queued.job.SetQueued(i + 1)
+	}
+}
@@ -0,0 +1,247 @@
+package job_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/job"
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func waitForJobs(t *testing.T, js *job.JobService, ownerID string, jobIDs ...string) {
+	for _, jobID := range jobIDs {
+		j, _ := js.Get(runnable.Principal{ID: ownerID}, jobID)
+		select {
+		case <-j.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected job %v to finish", jobID)
+		}
+	}
+}
+
+func TestQueueRunsJobsInOrder(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{MaxRunning: 1}, nil)
+
+	var jobIDs []string
+	for i := 0; i < 3; i++ {
+		jobID, err := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+		if err != nil {
+			t.Fatalf("expected no errors, got %v", err)
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+
+	for i, jobID := range jobIDs {
+		j, _ := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
+		status := j.Status()
+
+		expected := runnable.State(runnable.Queued)
+		if i == 0 {
+			expected = runnable.Running
+		}
+		if status.State != expected || status.QueuePosition != i {
+			t.Errorf("expected job %v to be %v at position %v, got %v at %v", i, expected, i, status.State, status.QueuePosition)
+		}
+	}
+
+	waitForJobs(t, js, "ownerID", jobIDs...)
+
+	// each one only started once the one before it had finished
+	var previous runnable.Status
+	for i, jobID := range jobIDs {
+		j, _ := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
+		status := j.Status()
+		if status.State != runnable.Completed {
+			t.Errorf("expected job %v to be %v, got %v", i, runnable.Completed, status.State)
+		}
+		if i > 0 && status.StartTime.Before(previous.EndTime) {
+			t.Errorf("expected job %v to start after job %v finished", i, i-1)
+		}
+		previous = status
+	}
+}
+
+func TestQueuePerOwnerLimit(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{MaxRunningPerOwner: 1}, nil)
+
+	first, _ := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+	second, _ := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+	other, _ := js.Start(runnable.Principal{ID: "bob"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+
+	// alice being at her limit does not hold up bob
+	for jobID, expected := range map[string]runnable.State{first: runnable.Running, second: runnable.Queued} {
+		j, _ := js.Get(runnable.Principal{ID: "alice"}, jobID)
+		if state := j.Status().State; state != expected {
+			t.Errorf("expected state %v, got %v", expected, state)
+		}
+	}
+	if j, _ := js.Get(runnable.Principal{ID: "bob"}, other); j.Status().State != runnable.Running {
+		t.Errorf("expected state %v, got %v", runnable.Running, j.Status().State)
+	}
+
+	waitForJobs(t, js, "alice", first, second)
+}
+
+func TestQueuedJobThatFailsToStart(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{MaxRunning: 1}, nil)
+
+	running, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+	failing, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "command-that-does-not-exist"})
+	next, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo"})
+
+	// the slot the failed job was given goes to the next one
+	waitForJobs(t, js, "ownerID", running, failing, next)
+
+	for jobID, expected := range map[string]runnable.State{failing: runnable.Failed, next: runnable.Completed} {
+		j, _ := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
+		if state := j.Status().State; state != expected {
+			t.Errorf("expected state %v, got %v", expected, state)
+		}
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{MaxRunning: 1, MaxQueued: 1}, nil)
+
+	running, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"2"}})
+	defer js.Stop(runnable.Principal{ID: "ownerID"}, running, runnable.StopOptions{})
+	queued, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo"})
+
+	_, err := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo"})
+	if runnable.ErrorCode(err) != runnable.EUNAVAILABLE {
+		t.Errorf("expected error type %v, got error %v", runnable.EUNAVAILABLE, err)
+	}
+
+	// the rejected job is not kept
+	if jobs := jss.List(runnable.JobFilter{}); len(jobs) != 2 {
+		t.Errorf("expected 2 jobs to be stored, got %v", len(jobs))
+	}
+
+	// stopping a queued job takes it out of the queue, without it ever running
+	if err := js.Stop(runnable.Principal{ID: "ownerID"}, queued, runnable.StopOptions{}); err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+
+	j, _ := js.Get(runnable.Principal{ID: "ownerID"}, queued)
+	if status := j.Status(); status.State != runnable.Stopped || !status.StartTime.IsZero() {
+		t.Errorf("expected job to be %v without starting, got %v", runnable.Stopped, status)
+	}
+
+	if _, err := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo"}); err != nil {
+		t.Errorf("expected room in the queue, got %v", err)
+	}
+}
+
+func TestQueueFairShare(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{
+		MaxRunning: 1,
+		Weights:    map[string]int{"alice": 3},
+	}, auth.NewPolicyAuthorizer(auth.Policy{Users: map[string]auth.Role{"admin": auth.RoleAdmin}}))
+
+	running, _ := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+	var alice, bob []string
+	for i := 0; i < 3; i++ {
+		jobID, _ := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "echo"})
+		alice = append(alice, jobID)
+	}
+	for i := 0; i < 2; i++ {
+		jobID, _ := js.Start(runnable.Principal{ID: "bob"}, runnable.JobSpec{Command: "echo"})
+		bob = append(bob, jobID)
+	}
+
+	// positions are within each owner's own queue
+	for ownerID, jobIDs := range map[string][]string{"alice": alice, "bob": bob} {
+		for i, jobID := range jobIDs {
+			j, _ := js.Get(runnable.Principal{ID: ownerID}, jobID)
+			if position := j.Status().QueuePosition; position != i+1 {
+				t.Errorf("expected %v's job to be at position %v, got %v", ownerID, i+1, position)
+			}
+		}
+	}
+
+	if _, err := js.Shares(runnable.Principal{ID: "alice"}); runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected only admins to see the shares, got %v", err)
+	}
+
+	shares, _ := js.Shares(runnable.Principal{ID: "admin"})
+	if len(shares) != 2 {
+		t.Fatalf("expected shares of 2 owners, got %v", shares)
+	}
+	if s := shares[0]; s.OwnerID != "alice" || s.Weight != 3 || s.Share != 0.75 || s.Usage != 1 || s.Running != 1 || s.Queued != 3 {
+		t.Errorf("unexpected share for alice %+v", s)
+	}
+	if s := shares[1]; s.OwnerID != "bob" || s.Weight != 1 || s.Share != 0.25 || s.Usage != 0 || s.Running != 0 || s.Queued != 2 {
+		t.Errorf("unexpected share for bob %+v", s)
+	}
+
+	waitForJobs(t, js, "alice", append([]string{running}, alice...)...)
+	waitForJobs(t, js, "bob", bob...)
+
+	// bob has not started anything, but catches up with alice rather than getting the slots she is queued for
+	// ahead of her, and then alice gets three for bob's one
+	order := []struct {
+		ownerID string
+		jobID   string
+	}{
+		{"alice", alice[0]},
+		{"bob", bob[0]},
+		{"alice", alice[1]},
+		{"alice", alice[2]},
+		{"bob", bob[1]},
+	}
+	var previous time.Time
+	for i, o := range order {
+		j, _ := js.Get(runnable.Principal{ID: o.ownerID}, o.jobID)
+		status := j.Status()
+		if status.StartTime.Before(previous) {
+			t.Errorf("expected job %v of %v to start after the one before it finished", i+1, o.ownerID)
+		}
+		previous = status.EndTime
+	}
+
+	// slots are freed just after the jobs are done
+	deadline := time.Now().Add(time.Second)
+	shares, _ = js.Shares(runnable.Principal{ID: "admin"})
+	for len(shares) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		shares, _ = js.Shares(runnable.Principal{ID: "admin"})
+	}
+	if len(shares) != 0 {
+		t.Errorf("expected no shares once every job has finished, got %v", shares)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{MaxRunning: 1}, nil)
+
+	running, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"0.2"}})
+	low, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo", Priority: -1})
+	normal, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo"})
+	high, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo", Priority: 5})
+	alsoHigh, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo", Priority: 5})
+
+	// higher priorities first, and in the order they were started within the same priority
+	for i, jobID := range []string{high, alsoHigh, normal, low} {
+		j, _ := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
+		if position := j.Status().QueuePosition; position != i+1 {
+			t.Errorf("expected job to be at position %v, got %v", i+1, position)
+		}
+	}
+
+	waitForJobs(t, js, "ownerID", running, low, normal, high, alsoHigh)
+}
@@ -1,8 +1,11 @@
 package job
 
 import (
+	"fmt"
 	"io"
+	"log"
 
+	"github.com/ambardhesi/runnable/pkg/auth"
 	"github.com/ambardhesi/runnable/pkg/runnable"
 )
 
@@ -10,65 +13,120 @@ import (
 type JobService struct {
 	jobStoreSvc runnable.JobStoreService
 	logFileSvc  runnable.LogFileService
+	cgroupSvc   runnable.CgroupService
+	scheduler   *scheduler
+	authorizer  runnable.Authorizer
 }
 
-func NewJobService(jobStoreSvc runnable.JobStoreService, logFileSvc runnable.LogFileService) *JobService {
+// cgroupSvc can be nil, in which case jobs are run without resource limits.
+// authorizer can be nil, in which case users can only get at their own jobs.
+func NewJobService(jobStoreSvc runnable.JobStoreService, logFileSvc runnable.LogFileService,
+	cgroupSvc runnable.CgroupService, schedulerConfig SchedulerConfig, authorizer runnable.Authorizer) *JobService {
+	if authorizer == nil {
+		authorizer = auth.NewPolicyAuthorizer(auth.Policy{})
+	}
+
 	return &JobService{
 		jobStoreSvc: jobStoreSvc,
 		logFileSvc:  logFileSvc,
+		cgroupSvc:   cgroupSvc,
+		scheduler:   newScheduler(schedulerConfig),
+		authorizer:  authorizer,
 	}
 }
 
-func (jobSvc *JobService) Start(ownerID string, command string, args ...string) (string, error) {
-	job, err := runnable.NewJob(ownerID, command, args...)
+func (jobSvc *JobService) Start(principal runnable.Principal, spec runnable.JobSpec) (string, error) {
+	err := jobSvc.authorizer.Authorize(principal, runnable.ActionStart, principal.ID)
 	if err != nil {
 		return "", err
 	}
 
-	logFile, err := jobSvc.logFileSvc.CreateLogFile(job.ID)
+	err = jobSvc.authorizer.AuthorizeJob(principal, spec)
 	if err != nil {
 		return "", err
 	}
 
-	job.SetLogWriter(logFile)
+	if jobSvc.cgroupSvc == nil && !spec.Limits.IsZero() {
+		return "", &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      "JobService.Start",
+			Message: "Resource limits are not enabled on this server.",
+		}
+	}
 
-	err = jobSvc.jobStoreSvc.Store(job)
+	job, err := runnable.NewJob(principal.ID, spec)
 	if err != nil {
-		// TODO delete the created log file for this job
-		// all log files will be deleted on server shutdown, so skipping this for now
 		return "", err
 	}
 
-	go func() {
-		job.Start()
-	}()
+	// the job is Queued if it can't run yet
+	prepared := false
+	err = jobSvc.scheduler.submit(job, func() error {
+		err := jobSvc.prepare(job)
+		prepared = err == nil
+		return err
+	})
+	if err != nil && prepared {
+		// the job is stored as Failed, and can still be looked at
+		return job.ID, err
+	}
+	if err != nil {
+		return "", err
+	}
 
 	return job.ID, nil
 
 }
 
-func (jobSvc *JobService) Stop(ownerID string, jobID string) error {
-	job, exists := jobSvc.jobStoreSvc.Get(jobID)
-	if !exists {
-		return &runnable.Error{
-			Code:    runnable.ENOTFOUND,
-			Op:      "JobService.Stop",
-			Message: "Job does not exist.",
+// Sets up the job's log file and cgroup, and stores it.
+func (jobSvc *JobService) prepare(job *runnable.Job) error {
+	logFile, err := jobSvc.logFileSvc.CreateLogFile(job.ID)
+	if err != nil {
+		return err
+	}
+
+	job.SetLogWriter(logFile)
+
+	var cg runnable.Cgroup
+	if jobSvc.cgroupSvc != nil {
+		cg, err = jobSvc.cgroupSvc.Create(job.ID, job.Spec.Limits)
+		if err != nil {
+			jobSvc.deleteLogFile(job.ID, logFile)
+			return err
 		}
+
+		job.SetCgroup(cg)
 	}
 
-	// TODO move auth into its own service
-	// something that takes in a jobID and ownerID and looks up whatever it needs to to make a decision
-	// this could be something like a DB call (via job service), or an external service call
-	if job.OwnerID != ownerID {
-		return &runnable.Error{
-			Code:    runnable.EUNAUTHORIZED,
-			Op:      "JobService.Stop",
-			Message: "User is unauthorized.",
+	// stored last, so every stored job has what it needs to start
+	err = jobSvc.jobStoreSvc.Store(job)
+	if err != nil {
+		jobSvc.deleteLogFile(job.ID, logFile)
+		if cg != nil {
+			_ = cg.Delete()
 		}
+		return err
+	}
+
+	return nil
+}
+
+// Closes and deletes the log file of a job that was never stored, so nothing else can read it.
+func (jobSvc *JobService) deleteLogFile(jobID string, logFile runnable.LogWriter) {
+	_ = logFile.Close()
+	err := jobSvc.logFileSvc.DeleteLogFile(jobID)
+	if err != nil {
+		log.Printf("Failed to delete log file of job %v %v\n", jobID, err)
+	}
+}
+
+func (jobSvc *JobService) Stop(principal runnable.Principal, jobID string, opts runnable.StopOptions) error {
+	job, err := jobSvc.authorizedJob(principal, jobID, runnable.ActionStop, "JobService.Stop")
+	if err != nil {
+		return err
 	}
 
-	err := job.Stop()
+	err = jobSvc.scheduler.stop(job, opts)
 	if err != nil {
 		return err
 	}
@@ -76,65 +134,95 @@ func (jobSvc *JobService) Stop(ownerID string, jobID string) error {
 	return nil
 }
 
-func (jobSvc *JobService) Get(ownerID string, jobID string) (*runnable.Job, error) {
+func (jobSvc *JobService) Pause(principal runnable.Principal, jobID string) error {
+	job, err := jobSvc.authorizedJob(principal, jobID, runnable.ActionStop, "JobService.Pause")
+	if err != nil {
+		return err
+	}
+
+	return job.Pause()
+}
+
+func (jobSvc *JobService) Resume(principal runnable.Principal, jobID string) error {
+	job, err := jobSvc.authorizedJob(principal, jobID, runnable.ActionStop, "JobService.Resume")
+	if err != nil {
+		return err
+	}
+
+	return job.Resume()
+}
+
+// Returns the job, if the principal may do the action to it.
+func (jobSvc *JobService) authorizedJob(principal runnable.Principal, jobID string, action runnable.Action, op string) (*runnable.Job, error) {
 	job, exists := jobSvc.jobStoreSvc.Get(jobID)
 	if !exists {
 		return nil, &runnable.Error{
 			Code:    runnable.ENOTFOUND,
-			Op:      "JobService.Get",
+			Op:      op,
 			Message: "Job does not exist.",
 		}
 	}
 
-	// TODO move auth into its own service
-	// something that takes in a jobID and ownerID and looks up whatever it needs to to make a decision
-	// this could be something like a DB call (via job service), or an external service call
-	if job.OwnerID != ownerID {
-		return nil, &runnable.Error{
-			Code:    runnable.EUNAUTHORIZED,
-			Op:      "JobService.Get",
-			Message: "User is unauthorized.",
-		}
+	err := jobSvc.authorizer.Authorize(principal, action, job.OwnerID)
+	if err != nil {
+		return nil, err
 	}
 
 	return job, nil
 }
 
-func (jobSvc *JobService) GetLogs(ownerID string, jobID string) (*string, error) {
-	job, exists := jobSvc.jobStoreSvc.Get(jobID)
-	if !exists {
-		return nil, &runnable.Error{
-			Code:    runnable.ENOTFOUND,
-			Op:      "JobService.GetLogs",
-			Message: "Job does not exist.",
+func (jobSvc *JobService) Get(principal runnable.Principal, jobID string) (*runnable.Job, error) {
+	return jobSvc.authorizedJob(principal, jobID, runnable.ActionRead, "JobService.Get")
+}
+
+// Lists the principal's own jobs, unless the filter asks for another owner's or runnable.AnyOwner's.
+func (jobSvc *JobService) List(principal runnable.Principal, opts runnable.ListOptions) (runnable.JobPage, error) {
+	switch opts.Filter.OwnerID {
+	case "":
+		opts.Filter.OwnerID = principal.ID
+	default:
+		err := jobSvc.authorizer.Authorize(principal, runnable.ActionRead, opts.Filter.OwnerID)
+		if err != nil {
+			return runnable.JobPage{}, err
+		}
+
+		if opts.Filter.OwnerID == runnable.AnyOwner {
+			opts.Filter.OwnerID = ""
 		}
 	}
-	// TODO move auth into its own service
-	// something that takes in a jobID and ownerID and looks up whatever it needs to to make a decision
-	// this could be something like a DB call (via job service), or an external service call
-	if job.OwnerID != ownerID {
+
+	return paginate(jobSvc.jobStoreSvc.List(opts.Filter), opts)
+}
+
+func (jobSvc *JobService) GetLogs(principal runnable.Principal, jobID string, opts runnable.LogOptions) (io.ReadCloser, error) {
+	job, err := jobSvc.authorizedJob(principal, jobID, runnable.ActionLogs, "JobService.GetLogs")
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Attempt < 0 || opts.Attempt > len(job.StoredStatus().Attempts) {
 		return nil, &runnable.Error{
-			Code:    runnable.EUNAUTHORIZED,
+			Code:    runnable.ENOTFOUND,
 			Op:      "JobService.GetLogs",
-			Message: "User is unauthorized.",
+			Message: fmt.Sprintf("Job has no attempt %v.", opts.Attempt),
 		}
 	}
 
+	// every reader gets its own file handle, so each one gets the logs from the start
 	file, err := jobSvc.logFileSvc.GetLogFile(jobID)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	// TODO we assume here that the file can fit in memory.
-	// Ideally we should read in chunks, and implement streaming
-
-	b, err := io.ReadAll(file)
+	return newLogReader(job, file, opts), nil
+}
 
+// Only includes owners with running or queued jobs.
+func (jobSvc *JobService) Shares(principal runnable.Principal) ([]runnable.OwnerShare, error) {
+	err := jobSvc.authorizer.Authorize(principal, runnable.ActionAdmin, runnable.AnyOwner)
 	if err != nil {
 		return nil, err
 	}
 
-	content := string(b)
-	return &content, nil
+	return jobSvc.scheduler.shares(), nil
 }
@@ -1,10 +1,15 @@
 package job_test
 
 import (
+	"io"
+	"os"
+	"path"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/ambardhesi/runnable/pkg/auth"
 	"github.com/ambardhesi/runnable/pkg/job"
 	"github.com/ambardhesi/runnable/pkg/repository"
 	"github.com/ambardhesi/runnable/pkg/runnable"
@@ -13,21 +18,21 @@ import (
 func TestEndToEnd(t *testing.T) {
 	lfs, _ := repository.NewLocalFileSystem("temp")
 	jss := repository.NewInMemoryDB()
-	js := job.NewJobService(jss, lfs)
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
 
 	// job sleeps for 2 seconds to give us time to do some assertions and to stop it
-	jobID, err := js.Start("ownerID", "sleep", "2")
+	jobID, err := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"2"}})
 	if err != nil {
 		t.Errorf("Did not expect to get an error starting job")
 	}
 	time.Sleep(200 * time.Millisecond)
 
-	job, err := js.Get("ownerID", jobID)
+	job, err := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
 	if err != nil {
 		t.Errorf("Did not expect to get an error fetching job with id %v", jobID)
 	}
 
-	err = js.Stop("ownerID", jobID)
+	err = js.Stop(runnable.Principal{ID: "ownerID"}, jobID, runnable.StopOptions{})
 	time.Sleep(200 * time.Millisecond)
 
 	if err != nil {
@@ -41,12 +46,53 @@ func TestEndToEnd(t *testing.T) {
 	lfs.DeleteAllLogFiles()
 }
 
+func TestStartCommandDoesNotExist(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	jobID, err := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "command-that-does-not-exist"})
+
+	if runnable.ErrorCode(err) != runnable.EINTERNAL {
+		t.Errorf("expected error type %v, got error%v", runnable.EINTERNAL, err)
+	}
+
+	// the job is kept, so the caller gets its ID
+	job, err := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
+	if err != nil {
+		t.Fatalf("expected the job that failed to start to be kept, got %v", err)
+	}
+	if status := job.Status(); status.State != runnable.Failed || status.Reason != runnable.ReasonStartFailed {
+		t.Errorf("expected job to be %v, got %v", runnable.Failed, status)
+	}
+
+	lfs.DeleteAllLogFiles()
+}
+
+func TestStartStoreFails(t *testing.T) {
+	logDir := t.TempDir()
+	lfs, _ := repository.NewLocalFileSystem(logDir)
+	jss, _ := repository.NewFileDB(path.Join(t.TempDir(), "jobs"))
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	// the store can no longer write jobs
+	jss.Close()
+	jobID, err := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "true"})
+	if runnable.ErrorCode(err) != runnable.EINTERNAL || jobID != "" {
+		t.Errorf("expected error type %v and no job ID, got %q %v", runnable.EINTERNAL, jobID, err)
+	}
+
+	if entries, _ := os.ReadDir(logDir); len(entries) != 0 {
+		t.Errorf("expected the job's log file to be deleted, got %v files", len(entries))
+	}
+}
+
 func TestStopJobDoesNotExist(t *testing.T) {
 	lfs, _ := repository.NewLocalFileSystem("temp")
 	jss := repository.NewInMemoryDB()
-	js := job.NewJobService(jss, lfs)
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
 
-	err := js.Stop("ownerID", "jobID")
+	err := js.Stop(runnable.Principal{ID: "ownerID"}, "jobID", runnable.StopOptions{})
 
 	if runnable.ErrorCode(err) != runnable.ENOTFOUND {
 		t.Errorf("expected error type %v, got error%v", runnable.ENOTFOUND, err)
@@ -58,9 +104,9 @@ func TestStopJobDoesNotExist(t *testing.T) {
 func TestGetJobDoesNotExist(t *testing.T) {
 	lfs, _ := repository.NewLocalFileSystem("temp")
 	jss := repository.NewInMemoryDB()
-	js := job.NewJobService(jss, lfs)
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
 
-	_, err := js.Get("ownerID", "jobID")
+	_, err := js.Get(runnable.Principal{ID: "ownerID"}, "jobID")
 
 	if runnable.ErrorCode(err) != runnable.ENOTFOUND {
 		t.Errorf("expected error type %v, got error%v", runnable.ENOTFOUND, err)
@@ -72,19 +118,82 @@ func TestGetJobDoesNotExist(t *testing.T) {
 func TestGetLogs(t *testing.T) {
 	lfs, _ := repository.NewLocalFileSystem("temp")
 	jss := repository.NewInMemoryDB()
-	js := job.NewJobService(jss, lfs)
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
 
-	jobID, _ := js.Start("ownerID", "echo", "hello world")
+	jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo", Args: []string{"hello world"}})
 	time.Sleep(200 * time.Millisecond)
 
-	logs, err := js.GetLogs("ownerID", jobID)
+	logs, err := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{})
 
 	if err != nil {
-		t.Errorf("expected no errors, got %v", err)
+		t.Fatalf("expected no errors, got %v", err)
 	}
+	defer logs.Close()
+
+	b, _ := io.ReadAll(logs)
+	if !strings.Contains(string(b), "hello world") {
+		t.Errorf("expected logs to contain %v, got %v", "hello world", string(b))
+	}
+
+	lfs.DeleteAllLogFiles()
+}
+
+func TestFollowLogs(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sh", Args: []string{"-c", "echo one; sleep 0.5; echo two"}})
+
+	// every follower gets everything, from the start
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-	if !strings.Contains(*logs, "hello world") {
-		t.Errorf("expected logs to contain %v, got %v", "hello world", logs)
+			logs, err := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Follow: true})
+			if err != nil {
+				t.Errorf("expected no errors, got %v", err)
+				return
+			}
+			defer logs.Close()
+
+			b, _ := io.ReadAll(logs)
+			if string(b) != "one\ntwo\n" {
+				t.Errorf("expected logs %q, got %q", "one\ntwo\n", string(b))
+			}
+		}()
+	}
+
+	wg.Wait()
+	lfs.DeleteAllLogFiles()
+}
+
+func TestFollowLogsClose(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"2"}})
+	defer js.Stop(runnable.Principal{ID: "ownerID"}, jobID, runnable.StopOptions{})
+
+	logs, _ := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Follow: true})
+
+	read := make(chan struct{})
+	go func() {
+		_, _ = io.ReadAll(logs)
+		close(read)
+	}()
+
+	// a follower of a running job only stops once it is closed
+	time.Sleep(200 * time.Millisecond)
+	logs.Close()
+
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Errorf("expected closing the logs to stop the follow")
 	}
 
 	lfs.DeleteAllLogFiles()
@@ -93,9 +202,9 @@ func TestGetLogs(t *testing.T) {
 func TestGetLogsJobDoesNotExist(t *testing.T) {
 	lfs, _ := repository.NewLocalFileSystem("temp")
 	jss := repository.NewInMemoryDB()
-	js := job.NewJobService(jss, lfs)
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
 
-	_, err := js.GetLogs("ownerID", "jobID")
+	_, err := js.GetLogs(runnable.Principal{ID: "ownerID"}, "jobID", runnable.LogOptions{})
 
 	if runnable.ErrorCode(err) != runnable.ENOTFOUND {
 		t.Errorf("expected error type %v, got error%v", runnable.ENOTFOUND, err)
@@ -103,3 +212,267 @@ func TestGetLogsJobDoesNotExist(t *testing.T) {
 
 	lfs.DeleteAllLogFiles()
 }
+
+func TestGetLogsOfOneStream(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sh", Args: []string{"-c", "echo out; echo err >&2"}})
+	time.Sleep(200 * time.Millisecond)
+
+	for stream, expected := range map[runnable.LogStream]string{runnable.Stdout: "out\n", runnable.Stderr: "err\n"} {
+		logs, err := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Stream: stream})
+		if err != nil {
+			t.Fatalf("expected no errors, got %v", err)
+		}
+
+		b, _ := io.ReadAll(logs)
+		logs.Close()
+		if string(b) != expected {
+			t.Errorf("expected %v logs %q, got %q", stream, expected, string(b))
+		}
+	}
+
+	lfs.DeleteAllLogFiles()
+}
+
+func TestGetLogsOfOneAttempt(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{}, nil)
+
+	jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{
+		Command: "echo run $(date +%N); exit 1",
+		Shell:   true,
+		Restart: runnable.RestartPolicy{
+			Mode:       runnable.RestartOnFailure,
+			MaxRetries: 2,
+			Backoff:    time.Millisecond,
+		},
+	})
+
+	// followed from the start, the first attempt's logs end with it rather than with the job
+	logs, err := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Follow: true, Attempt: 1})
+	if err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+	first, _ := io.ReadAll(logs)
+	logs.Close()
+
+	j, _ := js.Get(runnable.Principal{ID: "ownerID"}, jobID)
+	<-j.Done()
+
+	all, _ := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{})
+	b, _ := io.ReadAll(all)
+	all.Close()
+
+	lines := strings.SplitAfter(string(b), "\n")
+	if len(lines) != 4 || string(first) != lines[0] {
+		t.Fatalf("expected the first of 3 runs, got %q of %q", string(first), string(b))
+	}
+
+	for i, line := range lines[:3] {
+		logs, _ := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Attempt: i + 1})
+		b, _ := io.ReadAll(logs)
+		logs.Close()
+		if string(b) != line {
+			t.Errorf("expected logs of attempt %v to be %q, got %q", i+1, line, string(b))
+		}
+	}
+
+	_, err = js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Attempt: 4})
+	if runnable.ErrorCode(err) != runnable.ENOTFOUND {
+		t.Errorf("expected error type %v, got error %v", runnable.ENOTFOUND, err)
+	}
+}
+
+func TestGetLogsWithTimestamps(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sh", Args: []string{"-c", "printf 'one\\ntw'; sleep 0.1; echo o"}})
+	time.Sleep(400 * time.Millisecond)
+
+	logs, _ := js.GetLogs(runnable.Principal{ID: "ownerID"}, jobID, runnable.LogOptions{Timestamps: true})
+	defer logs.Close()
+	b, _ := io.ReadAll(logs)
+
+	// every line gets one timestamp, even when it was written in more than one go
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	expected := []string{"one", "two"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %v lines, got %q", len(expected), string(b))
+	}
+
+	for i, line := range lines {
+		fields := strings.SplitN(line, " ", 2)
+		if _, err := time.Parse(time.RFC3339Nano, fields[0]); err != nil || len(fields) != 2 || fields[1] != expected[i] {
+			t.Errorf("expected timestamped line %q, got %q", expected[i], line)
+		}
+	}
+
+	lfs.DeleteAllLogFiles()
+}
+
+func TestListFiltersJobs(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	before := time.Now()
+	echoID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo", Labels: map[string]string{"team": "a"}})
+	sleepID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "sleep", Args: []string{"2"}, Labels: map[string]string{"team": "b"}})
+	defer js.Stop(runnable.Principal{ID: "ownerID"}, sleepID, runnable.StopOptions{})
+	_, _ = js.Start(runnable.Principal{ID: "otherOwnerID"}, runnable.JobSpec{Command: "echo"})
+	time.Sleep(200 * time.Millisecond)
+
+	tests := map[string]struct {
+		filter   runnable.JobFilter
+		expected []string
+	}{
+		"owner":   {runnable.JobFilter{}, []string{sleepID, echoID}},
+		"state":   {runnable.JobFilter{States: []runnable.State{runnable.Completed}}, []string{echoID}},
+		"command": {runnable.JobFilter{CommandPrefix: "sl"}, []string{sleepID}},
+		"label":   {runnable.JobFilter{Labels: map[string]string{"team": "a"}}, []string{echoID}},
+		"since":   {runnable.JobFilter{StartedAfter: time.Now()}, nil},
+		"until":   {runnable.JobFilter{StartedBefore: before}, nil},
+	}
+
+	for name, test := range tests {
+		page, err := js.List(runnable.Principal{ID: "ownerID"}, runnable.ListOptions{Filter: test.filter})
+		if err != nil {
+			t.Fatalf("expected no errors, got %v", err)
+		}
+
+		var ids []string
+		for _, j := range page.Jobs {
+			ids = append(ids, j.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("%v: expected jobs %v, got %v", name, test.expected, ids)
+		}
+	}
+
+	lfs.DeleteAllLogFiles()
+}
+
+func TestListPages(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	jss := repository.NewInMemoryDB()
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, nil)
+
+	var started []string
+	for i := 0; i < 5; i++ {
+		jobID, _ := js.Start(runnable.Principal{ID: "ownerID"}, runnable.JobSpec{Command: "echo"})
+		started = append(started, jobID)
+	}
+
+	var listed []string
+	opts := runnable.ListOptions{Limit: 2, Ascending: true}
+	for {
+		page, err := js.List(runnable.Principal{ID: "ownerID"}, opts)
+		if err != nil {
+			t.Fatalf("expected no errors, got %v", err)
+		}
+		if len(page.Jobs) > 2 {
+			t.Fatalf("expected at most 2 jobs, got %v", len(page.Jobs))
+		}
+
+		for _, j := range page.Jobs {
+			listed = append(listed, j.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	if strings.Join(listed, ",") != strings.Join(started, ",") {
+		t.Errorf("expected jobs %v in the order they were started, got %v", started, listed)
+	}
+
+	_, err := js.List(runnable.Principal{ID: "ownerID"}, runnable.ListOptions{Cursor: "not a cursor"})
+	if runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v, got error%v", runnable.EINVALID, err)
+	}
+
+	lfs.DeleteAllLogFiles()
+}
+
+func TestRolesGrantAccessToOtherOwnersJobs(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{Users: map[string]auth.Role{
+		"olivia": auth.RoleOperator,
+		"victor": auth.RoleViewer,
+	}})
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{}, authorizer)
+
+	jobID, _ := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "sleep", Args: []string{"2"}})
+
+	for _, userID := range []string{"alice", "olivia", "victor"} {
+		if _, err := js.Get(runnable.Principal{ID: userID}, jobID); err != nil {
+			t.Errorf("expected %v to get the job, got %v", userID, err)
+		}
+	}
+	if _, err := js.Get(runnable.Principal{ID: "bob"}, jobID); runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected bob not to get the job, got %v", err)
+	}
+
+	page, err := js.List(runnable.Principal{ID: "victor"}, runnable.ListOptions{Filter: runnable.JobFilter{OwnerID: runnable.AnyOwner}})
+	if err != nil || len(page.Jobs) != 1 || page.Jobs[0].ID != jobID {
+		t.Errorf("expected victor to list every owner's jobs, got %v %v", page.Jobs, err)
+	}
+	if _, err := js.List(runnable.Principal{ID: "bob"}, runnable.ListOptions{Filter: runnable.JobFilter{OwnerID: "alice"}}); runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected bob not to list alice's jobs, got %v", err)
+	}
+
+	if _, err := js.GetLogs(runnable.Principal{ID: "victor"}, jobID, runnable.LogOptions{}); runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected victor not to get the logs, got %v", err)
+	}
+	logs, err := js.GetLogs(runnable.Principal{ID: "olivia"}, jobID, runnable.LogOptions{})
+	if err != nil {
+		t.Errorf("expected olivia to get the logs, got %v", err)
+	} else {
+		logs.Close()
+	}
+
+	err = js.Stop(runnable.Principal{ID: "victor"}, jobID, runnable.StopOptions{})
+	if runnable.ErrorCode(err) != runnable.EUNAUTHORIZED || !strings.Contains(err.Error(), "as a viewer") {
+		t.Errorf("expected victor not to stop the job, with the reason, got %v", err)
+	}
+	if err := js.Stop(runnable.Principal{ID: "olivia"}, jobID, runnable.StopOptions{}); err != nil {
+		t.Errorf("expected olivia to stop the job, got %v", err)
+	}
+
+	waitForJobs(t, js, "alice", jobID)
+}
+
+func TestStartChecksCommandPolicy(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	jss := repository.NewInMemoryDB()
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{Commands: []auth.CommandRule{
+		{Users: []string{"alice"}, Commands: []string{"/bin/echo"}, Args: []string{"[a-z]+"}},
+	}})
+	js := job.NewJobService(jss, lfs, nil, job.SchedulerConfig{}, authorizer)
+
+	if _, err := js.Start(runnable.Principal{ID: "bob"}, runnable.JobSpec{Command: "/bin/echo"}); runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected bob not to run echo, got %v", err)
+	}
+	if _, err := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "/bin/echo", Args: []string{"--help"}}); runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected alice not to run echo with --help, got %v", err)
+	}
+	if jobs := jss.List(runnable.JobFilter{}); len(jobs) != 0 {
+		t.Errorf("expected rejected jobs not to be stored, got %v", jobs)
+	}
+
+	jobID, err := js.Start(runnable.Principal{ID: "alice"}, runnable.JobSpec{Command: "/bin/echo", Args: []string{"hello"}})
+	if err != nil {
+		t.Fatalf("expected alice to run echo hello, got %v", err)
+	}
+	waitForJobs(t, js, "alice", jobID)
+}
@@ -33,3 +33,16 @@ func (db *InMemoryDB) Get(jobID string) (*runnable.Job, bool) {
 	job, exists := db.jobs[jobID]
 	return job, exists
 }
+
+func (db *InMemoryDB) List(filter runnable.JobFilter) []*runnable.Job {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var jobs []*runnable.Job
+	for _, job := range db.jobs {
+		if filter.Matches(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
@@ -0,0 +1,363 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+const (
+	userReadWritePermission = 0600
+
+	// the journal is compacted once it has this many records more than twice the number of jobs
+	compactSlack = 1000
+
+	// longer lines are skipped when reading the journal, rather than read into memory whole
+	maxRecordSize = 1 << 20
+	// only a job's latest attempts are recorded, so that a job that keeps restarting has records of a bounded size
+	maxRecordedAttempts = 100
+)
+
+var errRecordTooLong = errors.New("job record too long")
+
+// What is recorded about a job, every time its status changes.
+type jobRecord struct {
+	ID      string           `json:"id"`
+	OwnerID string           `json:"ownerID"`
+	Spec    runnable.JobSpec `json:"spec"`
+	Status  runnable.Status  `json:"status"`
+}
+
+// Implementation of runnable.JobStoreService that keeps jobs in a file, so they survive restarts.
+// The file is a journal with a line of JSON for each job every time its status changes, the last one wins.
+// It is compacted to a single line per job when it is opened, and whenever it has grown too long.
+type FileDB struct {
+	filePath string
+	jobs     map[string]*runnable.Job
+	lock     sync.RWMutex
+	// guards the file, and is only taken by writers, so Get and List never wait on the disk
+	file     *os.File
+	records  int
+	fileLock sync.Mutex
+}
+
+// Opens the job store in the given file, creating it if it does not exist.
+// Jobs that were still running when the file was last written to are marked as Lost.
+func NewFileDB(filePath string) (*FileDB, error) {
+	op := "repository.NewFileDB"
+
+	records, err := readRecords(filePath)
+	if err != nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to read job store.",
+			Err:     err,
+		}
+	}
+
+	jobs := make(map[string]*runnable.Job)
+	for _, record := range records {
+		// nothing is running these jobs any more, and their processes were not waited for
+		switch record.Status.State {
+		case runnable.NotStarted, runnable.Queued, runnable.Running, runnable.Restarting, runnable.Paused:
+			record.Status.State = runnable.Lost
+			record.Status.Reason = runnable.ReasonLost
+			record.Status.QueuePosition = 0
+			record.Status.NextAttempt = time.Time{}
+			record.Status.Live = nil
+		}
+
+		jobs[record.ID] = runnable.RestoreJob(record.ID, record.OwnerID, record.Spec, record.Status)
+	}
+
+	err = writeRecords(filePath, records)
+	if err != nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to compact job store.",
+			Err:     err,
+		}
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, userReadWritePermission)
+	if err != nil {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Failed to open job store.",
+			Err:     err,
+		}
+	}
+
+	return &FileDB{
+		filePath: filePath,
+		jobs:     jobs,
+		file:     file,
+		records:  len(records),
+	}, nil
+}
+
+// Reads the latest record of every job in the file, in the order the jobs were first stored.
+func readRecords(filePath string) ([]*jobRecord, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*jobRecord
+	latest := make(map[string]*jobRecord)
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := readLine(reader)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err == errRecordTooLong {
+			log.Printf("Skipping job record longer than %v bytes\n", maxRecordSize)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var record jobRecord
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			// the server can die half way through a line, which only loses that update
+			log.Printf("Skipping unreadable job record %v\n", err)
+			continue
+		}
+
+		if existing, ok := latest[record.ID]; ok {
+			*existing = record
+			continue
+		}
+
+		latest[record.ID] = &record
+		records = append(records, &record)
+	}
+}
+
+// Returns the next line without its newline, or errRecordTooLong if it is longer than maxRecordSize, in which
+// case the rest of it is skipped. Returns io.EOF once there are no lines left.
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if !tooLong {
+			line = append(line, chunk...)
+			if len(line) > maxRecordSize {
+				line, tooLong = nil, true
+			}
+		}
+
+		if !isPrefix {
+			if tooLong {
+				return nil, errRecordTooLong
+			}
+			return line, nil
+		}
+	}
+}
+
+// Replaces the file with one holding just the given records.
+func writeRecords(filePath string, records []*jobRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		err := encoder.Encode(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return replaceFile(filePath, buf.Bytes())
+}
+
+// Replaces the file's contents with data, so that the file either has all of it or its old contents.
+func replaceFile(filePath string, data []byte) error {
+	tempPath := filePath + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, userReadWritePermission)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, filePath)
+}
+
+func (db *FileDB) Store(job *runnable.Job) error {
+	db.lock.Lock()
+	db.jobs[job.ID] = job
+	db.lock.Unlock()
+
+	err := db.write(job)
+	if err != nil {
+		// the caller never hears of the job, so neither should anyone listing jobs
+		db.lock.Lock()
+		delete(db.jobs, job.ID)
+		db.lock.Unlock()
+		return err
+	}
+
+	job.SetStatusListener(db.statusChanged)
+
+	return nil
+}
+
+func (db *FileDB) Get(jobID string) (*runnable.Job, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	job, exists := db.jobs[jobID]
+	return job, exists
+}
+
+func (db *FileDB) List(filter runnable.JobFilter) []*runnable.Job {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var jobs []*runnable.Job
+	for _, job := range db.jobs {
+		if filter.Matches(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+func (db *FileDB) Close() error {
+	db.fileLock.Lock()
+	defer db.fileLock.Unlock()
+
+	return db.file.Close()
+}
+
+func (db *FileDB) statusChanged(job *runnable.Job) {
+	err := db.write(job)
+	if err != nil {
+		log.Printf("Failed to record status of job %v %v\n", job.ID, err)
+	}
+}
+
+// Returns what is recorded about the job. Only what outlives the job is kept, so not its live stats,
+// nor any but its latest maxRecordedAttempts attempts.
+func recordOf(job *runnable.Job) *jobRecord {
+	status := job.StoredStatus()
+	if len(status.Attempts) > maxRecordedAttempts {
+		status.Attempts = status.Attempts[len(status.Attempts)-maxRecordedAttempts:]
+	}
+
+	return &jobRecord{
+		ID:      job.ID,
+		OwnerID: job.OwnerID,
+		Spec:    job.Spec,
+		Status:  status,
+	}
+}
+
+// Appends the job's current status to the file.
+func (db *FileDB) write(job *runnable.Job) error {
+	// the status is read under the lock, so a later write never records an older status.
+	// It is only copied from the job, which never waits on the job's cgroup.
+	db.fileLock.Lock()
+	defer db.fileLock.Unlock()
+
+	line, err := json.Marshal(recordOf(job))
+	if err != nil {
+		return &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "FileDB.Store",
+			Message: "Failed to encode job.",
+			Err:     err,
+		}
+	}
+
+	// a single write, so a crash can only cut off the last line
+	_, err = db.file.Write(append(line, '\n'))
+	if err == nil {
+		err = db.file.Sync()
+	}
+	if err != nil {
+		return &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "FileDB.Store",
+			Message: "Failed to write job.",
+			Err:     err,
+		}
+	}
+	db.records++
+
+	db.lock.RLock()
+	jobs := make([]*runnable.Job, 0, len(db.jobs))
+	for _, job := range db.jobs {
+		jobs = append(jobs, job)
+	}
+	db.lock.RUnlock()
+
+	if db.records > 2*len(jobs)+compactSlack {
+		err = db.compact(jobs)
+		if err != nil {
+			// the journal is still whole, it just keeps growing until the next try
+			log.Printf("Failed to compact job store %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// Replaces the journal with a single record of every job.
+// Must be called with the file lock held.
+func (db *FileDB) compact(jobs []*runnable.Job) error {
+	records := make([]*jobRecord, len(jobs))
+	for i, job := range jobs {
+		records[i] = recordOf(job)
+	}
+
+	err := writeRecords(db.filePath, records)
+	if err != nil {
+		return err
+	}
+
+	// the old file has been replaced, so appending to it would lose every later record
+	file, err := os.OpenFile(db.filePath, os.O_WRONLY|os.O_APPEND, userReadWritePermission)
+	if err != nil {
+		return err
+	}
+	db.file.Close()
+	db.file = file
+	db.records = len(records)
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package repository_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func startJob(t *testing.T, db *repository.FileDB, spec runnable.JobSpec) *runnable.Job {
+	lfs, _ := repository.NewLocalFileSystem(t.TempDir())
+
+	job, _ := runnable.NewJob("ownerID", spec)
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	if err := db.Store(job); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	if err := job.Start(); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	return job
+}
+
+func TestFileDBRecordsStatusChanges(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "jobs")
+	db, err := repository.NewFileDB(filePath)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	job := startJob(t, db, runnable.JobSpec{Command: "sh", Args: []string{"-c", "exit 3"}})
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected job to finish")
+	}
+	db.Close()
+
+	db, _ = repository.NewFileDB(filePath)
+	defer db.Close()
+
+	restored, exists := db.Get(job.ID)
+	if !exists {
+		t.Fatalf("expected job %v to be restored", job.ID)
+	}
+
+	if restored.OwnerID != "ownerID" || restored.Spec.Command != "sh" {
+		t.Errorf("expected job to be restored with its owner and spec, got %v %v", restored.OwnerID, restored.Spec)
+	}
+
+	status := restored.Status()
+	if status.State != runnable.Completed || status.ExitCode != 3 || !status.EndTime.Equal(job.Status().EndTime) {
+		t.Errorf("expected status %v, got %v", job.Status(), status)
+	}
+}
+
+func TestFileDBMarksRunningJobsLost(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "jobs")
+	db, _ := repository.NewFileDB(filePath)
+
+	job := startJob(t, db, runnable.JobSpec{Command: "sleep", Args: []string{"10"}})
+	defer job.Stop(runnable.StopOptions{})
+
+	// a new store on the same file stands in for a restarted server
+	restarted, _ := repository.NewFileDB(filePath)
+	defer restarted.Close()
+
+	restored, _ := restarted.Get(job.ID)
+	if state := restored.Status().State; state != runnable.Lost {
+		t.Errorf("expected state %v, got %v", runnable.Lost, state)
+	}
+
+	select {
+	case <-restored.Done():
+	default:
+		t.Errorf("expected lost job to be done")
+	}
+
+	if err := restored.Stop(runnable.StopOptions{}); runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v, got error %v", runnable.EINVALID, err)
+	}
+}
+
+func TestFileDBSkipsTornRecord(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "jobs")
+	db, _ := repository.NewFileDB(filePath)
+	_ = db.Store(runnable.RestoreJob("id", "ownerID", runnable.JobSpec{Command: "true"}, runnable.Status{State: runnable.Completed}))
+	db.Close()
+
+	// the server died half way through writing the next record
+	f, _ := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+	_, _ = f.WriteString(`{"id":"id","status":{"Sta`)
+	f.Close()
+
+	db, err := repository.NewFileDB(filePath)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	defer db.Close()
+
+	if job, exists := db.Get("id"); !exists || job.Status().State != runnable.Completed {
+		t.Errorf("expected job to keep its last whole record")
+	}
+}
+
+func TestFileDBCompactsJournal(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "jobs")
+	db, _ := repository.NewFileDB(filePath)
+	job := runnable.RestoreJob("id", "ownerID", runnable.JobSpec{Command: "true"}, runnable.Status{State: runnable.Completed})
+
+	// every store appends a record, like a status change does
+	for i := 0; i < 1500; i++ {
+		if err := db.Store(job); err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+	}
+
+	b, _ := os.ReadFile(filePath)
+	if lines := strings.Count(string(b), "\n"); lines >= 1000 {
+		t.Errorf("expected the journal to have been compacted, got %v records", lines)
+	}
+
+	// records written after compacting go to the new file
+	db.Close()
+	db, _ = repository.NewFileDB(filePath)
+	defer db.Close()
+
+	if job, exists := db.Get("id"); !exists || job.Status().State != runnable.Completed {
+		t.Errorf("expected job to survive compaction")
+	}
+}
+
+func TestFileDBSkipsOversizedRecord(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "jobs")
+
+	// a record too long to read, before one that is fine
+	f, _ := os.Create(filePath)
+	_, _ = f.WriteString(`{"id":"big","spec":{"Command":"` + strings.Repeat("x", 2<<20) + "\"}}\n")
+	_, _ = f.WriteString(`{"id":"id","ownerID":"ownerID","spec":{"Command":"true"},"status":{"State":"Completed"}}` + "\n")
+	f.Close()
+
+	db, err := repository.NewFileDB(filePath)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	defer db.Close()
+
+	if _, exists := db.Get("big"); exists {
+		t.Errorf("expected the oversized record to be skipped")
+	}
+	if job, exists := db.Get("id"); !exists || job.Status().State != runnable.Completed {
+		t.Errorf("expected the record after it to be read")
+	}
+}
+
+func TestFileDBRecordsLatestAttempts(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "jobs")
+	db, _ := repository.NewFileDB(filePath)
+
+	attempts := make([]runnable.Attempt, 150)
+	for i := range attempts {
+		attempts[i].ExitCode = i
+	}
+	_ = db.Store(runnable.RestoreJob("id", "ownerID", runnable.JobSpec{Command: "true"},
+		runnable.Status{State: runnable.Completed, Attempts: attempts}))
+	db.Close()
+
+	db, _ = repository.NewFileDB(filePath)
+	defer db.Close()
+
+	job, _ := db.Get("id")
+	recorded := job.Status().Attempts
+	if len(recorded) != 100 || recorded[0].ExitCode != 50 || recorded[99].ExitCode != 149 {
+		t.Errorf("expected the latest 100 attempts to be recorded, got %v", len(recorded))
+	}
+}
+
+func TestFileDBStoreFails(t *testing.T) {
+	db, _ := repository.NewFileDB(path.Join(t.TempDir(), "jobs"))
+
+	// writes fail once the file is closed
+	db.Close()
+	err := db.Store(runnable.RestoreJob("id", "ownerID", runnable.JobSpec{Command: "true"}, runnable.Status{State: runnable.NotStarted}))
+	if runnable.ErrorCode(err) != runnable.EINTERNAL {
+		t.Errorf("expected error type %v, got error %v", runnable.EINTERNAL, err)
+	}
+
+	if _, exists := db.Get("id"); exists {
+		t.Errorf("expected a job that failed to be stored to be left out")
+	}
+	if jobs := db.List(runnable.JobFilter{}); len(jobs) != 0 {
+		t.Errorf("expected no jobs, got %v", len(jobs))
+	}
+}
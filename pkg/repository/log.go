@@ -1,15 +1,26 @@
 package repository
 
 import (
+	"encoding/binary"
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"time"
 
 	"github.com/ambardhesi/runnable/pkg/runnable"
 )
 
 const (
 	userReadWriteExecutePermission = 0700
+
+	// Log files are a sequence of records, each framed by a header of
+	// 1 byte stream, 8 bytes capture time (unix nanoseconds), 4 bytes payload length.
+	// All big endian.
+	recordHeaderSize = 1 + 8 + 4
+
+	stdoutStream byte = 1
+	stderrStream byte = 2
 )
 
 // implementation of runnable.LogFileService
@@ -32,7 +43,7 @@ func NewLocalFileSystem(dir string) (*LocalFileSystem, error) {
 	}, nil
 }
 
-func (lfs *LocalFileSystem) CreateLogFile(jobID string) (io.WriteCloser, error) {
+func (lfs *LocalFileSystem) CreateLogFile(jobID string) (runnable.LogWriter, error) {
 	logFile, err := os.Create(path.Join(lfs.dir, jobID))
 	if err != nil {
 		return nil, &runnable.Error{
@@ -43,10 +54,12 @@ func (lfs *LocalFileSystem) CreateLogFile(jobID string) (io.WriteCloser, error)
 		}
 	}
 
-	return logFile, nil
+	return &logFileWriter{
+		file: logFile,
+	}, nil
 }
 
-func (lfs *LocalFileSystem) GetLogFile(jobID string) (io.ReadCloser, error) {
+func (lfs *LocalFileSystem) GetLogFile(jobID string) (runnable.LogReader, error) {
 	logFile, err := os.Open(path.Join(lfs.dir, jobID))
 	if err != nil {
 		return nil, &runnable.Error{
@@ -57,7 +70,23 @@ func (lfs *LocalFileSystem) GetLogFile(jobID string) (io.ReadCloser, error) {
 		}
 	}
 
-	return logFile, nil
+	return &logFileReader{
+		file: logFile,
+	}, nil
+}
+
+func (lfs *LocalFileSystem) DeleteLogFile(jobID string) error {
+	err := os.Remove(path.Join(lfs.dir, jobID))
+	if err != nil {
+		return &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "LocalFileSystem.DeleteLogFile",
+			Message: "Failed to delete log file",
+			Err:     err,
+		}
+	}
+
+	return nil
 }
 
 func (lfs *LocalFileSystem) DeleteAllLogFiles() error {
@@ -73,3 +102,114 @@ func (lfs *LocalFileSystem) DeleteAllLogFiles() error {
 
 	return nil
 }
+
+// implementation of runnable.LogWriter
+type logFileWriter struct {
+	file *os.File
+}
+
+func (w *logFileWriter) WriteRecord(record runnable.LogRecord) error {
+	var stream byte
+	switch record.Stream {
+	case runnable.Stdout:
+		stream = stdoutStream
+	case runnable.Stderr:
+		stream = stderrStream
+	default:
+		return &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "logFileWriter.WriteRecord",
+			Message: fmt.Sprintf("Unknown log stream %q.", record.Stream),
+		}
+	}
+
+	frame := make([]byte, recordHeaderSize+len(record.Payload))
+	frame[0] = stream
+	binary.BigEndian.PutUint64(frame[1:9], uint64(record.Time.UnixNano()))
+	binary.BigEndian.PutUint32(frame[9:recordHeaderSize], uint32(len(record.Payload)))
+	copy(frame[recordHeaderSize:], record.Payload)
+
+	// a single write, so readers never see half a header followed by another record
+	_, err := w.file.Write(frame)
+	if err != nil {
+		return &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "logFileWriter.WriteRecord",
+			Message: "Failed to write log record.",
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+func (w *logFileWriter) Close() error {
+	return w.file.Close()
+}
+
+// implementation of runnable.LogReader
+type logFileReader struct {
+	file *os.File
+	// start of the next record
+	offset int64
+}
+
+func (r *logFileReader) ReadRecord() (runnable.LogRecord, error) {
+	op := "logFileReader.ReadRecord"
+
+	// a record that is only partly there is still being written, so treat it like the end of the file
+	// and read it again from the start next time
+	header := make([]byte, recordHeaderSize)
+	n, err := r.file.ReadAt(header, r.offset)
+	if n < recordHeaderSize {
+		return runnable.LogRecord{}, readError(op, err)
+	}
+
+	var stream runnable.LogStream
+	switch header[0] {
+	case stdoutStream:
+		stream = runnable.Stdout
+	case stderrStream:
+		stream = runnable.Stderr
+	default:
+		return runnable.LogRecord{}, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      op,
+			Message: "Log file is corrupt.",
+		}
+	}
+
+	captured := int64(binary.BigEndian.Uint64(header[1:9]))
+	length := binary.BigEndian.Uint32(header[9:recordHeaderSize])
+
+	payload := make([]byte, length)
+	n, err = r.file.ReadAt(payload, r.offset+recordHeaderSize)
+	if n < len(payload) {
+		return runnable.LogRecord{}, readError(op, err)
+	}
+
+	r.offset += recordHeaderSize + int64(length)
+
+	return runnable.LogRecord{
+		Stream:  stream,
+		Time:    time.Unix(0, captured),
+		Payload: payload,
+	}, nil
+}
+
+func readError(op string, err error) error {
+	if err == nil || err == io.EOF {
+		return io.EOF
+	}
+
+	return &runnable.Error{
+		Code:    runnable.EINTERNAL,
+		Op:      op,
+		Message: "Failed to read log record.",
+		Err:     err,
+	}
+}
+
+func (r *logFileReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestLogRecordsRoundTrip(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem(t.TempDir())
+
+	w, _ := lfs.CreateLogFile("jobID")
+	now := time.Now()
+	records := []runnable.LogRecord{
+		{Stream: runnable.Stdout, Time: now, Payload: []byte("out\n")},
+		{Stream: runnable.Stderr, Time: now.Add(time.Millisecond), Payload: []byte("err\n")},
+	}
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+	}
+	w.Close()
+
+	r, _ := lfs.GetLogFile("jobID")
+	defer r.Close()
+
+	for _, expected := range records {
+		record, err := r.ReadRecord()
+		if err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+		if record.Stream != expected.Stream || !record.Time.Equal(expected.Time) || string(record.Payload) != string(expected.Payload) {
+			t.Errorf("expected record %v, got %v", expected, record)
+		}
+	}
+
+	if _, err := r.ReadRecord(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestReadPartialLogRecord(t *testing.T) {
+	dir := t.TempDir()
+	lfs, _ := repository.NewLocalFileSystem(dir)
+
+	w, _ := lfs.CreateLogFile("jobID")
+	defer w.Close()
+	_ = w.WriteRecord(runnable.LogRecord{Stream: runnable.Stdout, Time: time.Now(), Payload: []byte("hello")})
+
+	// cut the record short, like a reader catching the writer half way through
+	info, _ := os.Stat(path.Join(dir, "jobID"))
+	_ = os.Truncate(path.Join(dir, "jobID"), info.Size()-2)
+
+	r, _ := lfs.GetLogFile("jobID")
+	defer r.Close()
+
+	if _, err := r.ReadRecord(); err != io.EOF {
+		t.Errorf("expected %v, got %v", io.EOF, err)
+	}
+
+	// once the rest is there, the whole record is read
+	_ = os.Truncate(path.Join(dir, "jobID"), info.Size())
+	f, _ := os.OpenFile(path.Join(dir, "jobID"), os.O_WRONLY, 0)
+	_, _ = f.WriteAt([]byte("lo"), info.Size()-2)
+	f.Close()
+
+	record, err := r.ReadRecord()
+	if err != nil || string(record.Payload) != "hello" {
+		t.Errorf("expected record %q, got %q %v", "hello", string(record.Payload), err)
+	}
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// Implementation of runnable.ScheduleStoreService
+type InMemoryScheduleDB struct {
+	schedules map[string]runnable.Schedule
+	lock      sync.RWMutex
+}
+
+func NewInMemoryScheduleDB() *InMemoryScheduleDB {
+	return &InMemoryScheduleDB{
+		schedules: make(map[string]runnable.Schedule),
+	}
+}
+
+func (db *InMemoryScheduleDB) Store(schedule runnable.Schedule) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.schedules[schedule.ID] = schedule
+	return nil
+}
+
+func (db *InMemoryScheduleDB) Get(scheduleID string) (runnable.Schedule, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	schedule, exists := db.schedules[scheduleID]
+	return schedule, exists
+}
+
+func (db *InMemoryScheduleDB) List() []runnable.Schedule {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var schedules []runnable.Schedule
+	for _, schedule := range db.schedules {
+		schedules = append(schedules, schedule)
+	}
+	return schedules
+}
+
+func (db *InMemoryScheduleDB) Delete(scheduleID string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	delete(db.schedules, scheduleID)
+	return nil
+}
+
+// Implementation of runnable.ScheduleStoreService that keeps schedules in a file as well, so they survive restarts.
+// The file is a JSON array of every schedule, rewritten on every change, as there are not expected to be many.
+type FileScheduleDB struct {
+	*InMemoryScheduleDB
+	filePath string
+	// serialises changes, so the file is always written with the latest schedules
+	lock sync.Mutex
+}
+
+// Opens the schedule store in the given file, creating it if it does not exist.
+func NewFileScheduleDB(filePath string) (*FileScheduleDB, error) {
+	db := &FileScheduleDB{
+		InMemoryScheduleDB: NewInMemoryScheduleDB(),
+		filePath:           filePath,
+	}
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "repository.NewFileScheduleDB",
+			Message: "Failed to read schedule store.",
+			Err:     err,
+		}
+	}
+
+	if len(data) > 0 {
+		var schedules []runnable.Schedule
+		err = json.Unmarshal(data, &schedules)
+		if err != nil {
+			return nil, &runnable.Error{
+				Code:    runnable.EINTERNAL,
+				Op:      "repository.NewFileScheduleDB",
+				Message: "Failed to decode schedule store.",
+				Err:     err,
+			}
+		}
+
+		for _, schedule := range schedules {
+			db.schedules[schedule.ID] = schedule
+		}
+	}
+
+	return db, nil
+}
+
+func (db *FileScheduleDB) Store(schedule runnable.Schedule) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	_ = db.InMemoryScheduleDB.Store(schedule)
+	return db.write()
+}
+
+func (db *FileScheduleDB) Delete(scheduleID string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	_ = db.InMemoryScheduleDB.Delete(scheduleID)
+	return db.write()
+}
+
+// Must be called with the lock held.
+func (db *FileScheduleDB) write() error {
+	schedules := db.List()
+	if schedules == nil {
+		schedules = []runnable.Schedule{}
+	}
+
+	data, err := json.Marshal(schedules)
+	if err == nil {
+		err = replaceFile(db.filePath, data)
+	}
+	if err != nil {
+		return &runnable.Error{
+			Code:    runnable.EINTERNAL,
+			Op:      "FileScheduleDB.Store",
+			Message: "Failed to write schedules.",
+			Err:     err,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestFileScheduleDBSurvivesReopening(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "schedules.json")
+	db, err := repository.NewFileScheduleDB(filePath)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	schedule := runnable.Schedule{
+		ID:        "kept",
+		OwnerID:   "ownerID",
+		Spec:      runnable.ScheduleSpec{Cron: "@hourly", Job: runnable.JobSpec{Command: "echo"}},
+		Paused:    true,
+		CreatedAt: time.Now().Round(0),
+	}
+	for _, s := range []runnable.Schedule{schedule, {ID: "deleted"}} {
+		if err := db.Store(s); err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+	}
+	if err := db.Delete("deleted"); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	db, err = repository.NewFileScheduleDB(filePath)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	if _, exists := db.Get("deleted"); exists {
+		t.Errorf("expected deleted schedule to stay deleted")
+	}
+
+	restored, exists := db.Get("kept")
+	if !exists {
+		t.Fatalf("expected schedule to be restored")
+	}
+	if restored.OwnerID != "ownerID" || restored.Spec.Cron != "@hourly" || restored.Spec.Job.Command != "echo" ||
+		!restored.Paused || !restored.CreatedAt.Equal(schedule.CreatedAt) {
+		t.Errorf("expected %v, got %v", schedule, restored)
+	}
+}
@@ -0,0 +1,36 @@
+package runnable
+
+type Action string
+
+// What a user can be allowed to do to jobs.
+const (
+	// Starting jobs, which are always the user's own.
+	ActionStart Action = "start"
+	// Getting and listing jobs, schedules and workflows.
+	ActionRead Action = "read"
+	// Getting the logs of jobs.
+	ActionLogs Action = "logs"
+	// Stopping, pausing and resuming jobs, and the same for schedules and workflows.
+	ActionStop Action = "stop"
+	// Seeing how the server is shared between owners.
+	ActionAdmin Action = "admin"
+)
+
+// Stands for the jobs of every owner, eg when listing them, or for none in particular.
+const AnyOwner = "*"
+
+// Who a request was made by, as taken from their client cert.
+type Principal struct {
+	// Owns the jobs the principal starts, and is what policies know them by.
+	ID string
+	// Groups the principal is in, eg the OUs of their cert, which policies can grant to as well.
+	Groups []string
+}
+
+type Authorizer interface {
+	// Returns EUNAUTHORIZED, with the reason, if the principal may not do the action to jobs owned by ownerID.
+	Authorize(principal Principal, action Action, ownerID string) error
+	// Returns EUNAUTHORIZED if the principal may not run the job's command at all, and EINVALID if they may,
+	// but not with the job's arguments, environment or working directory.
+	AuthorizeJob(principal Principal, spec JobSpec) error
+}
@@ -0,0 +1,83 @@
+package runnable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// The user and groups a job's processes run as.
+type Credential struct {
+	UID uint32
+	GID uint32
+	// Supplementary groups, the job has none if empty.
+	Groups []uint32
+}
+
+// Checks the credential only uses allowed IDs. Root is never allowed, even if it is in the allowlists.
+func (c Credential) Validate(allowedUIDs []uint32, allowedGIDs []uint32) error {
+	op := "Credential.Validate"
+
+	if c.UID == 0 || !containsID(allowedUIDs, c.UID) {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("Running as uid %v is not allowed.", c.UID),
+		}
+	}
+
+	for _, gid := range append([]uint32{c.GID}, c.Groups...) {
+		if gid == 0 || !containsID(allowedGIDs, gid) {
+			return &Error{
+				Code:    EINVALID,
+				Op:      op,
+				Message: fmt.Sprintf("Running as gid %v is not allowed.", gid),
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsID(ids []uint32, id uint32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Credential) sysCredential() *syscall.Credential {
+	return &syscall.Credential{
+		Uid:    c.UID,
+		Gid:    c.GID,
+		Groups: c.Groups,
+	}
+}
+
+// formats the groups as a comma separated list, for passing to the init process
+func formatGroups(groups []uint32) string {
+	s := make([]string, len(groups))
+	for i, g := range groups {
+		s[i] = strconv.FormatUint(uint64(g), 10)
+	}
+	return strings.Join(s, ",")
+}
+
+func parseGroups(s string) ([]uint32, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var groups []uint32
+	for _, field := range strings.Split(s, ",") {
+		g, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, uint32(g))
+	}
+	return groups, nil
+}
@@ -0,0 +1,37 @@
+package runnable_test
+
+import (
+	"testing"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestValidateCredential(t *testing.T) {
+	allowedUIDs := []uint32{0, 1000}
+	allowedGIDs := []uint32{0, 1000, 1001}
+
+	tests := []struct {
+		cred  runnable.Credential
+		valid bool
+	}{
+		{runnable.Credential{UID: 1000, GID: 1000}, true},
+		{runnable.Credential{UID: 1000, GID: 1000, Groups: []uint32{1001}}, true},
+		{runnable.Credential{UID: 1001, GID: 1000}, false},
+		{runnable.Credential{UID: 1000, GID: 1002}, false},
+		{runnable.Credential{UID: 1000, GID: 1000, Groups: []uint32{1002}}, false},
+		// root is never allowed, even when it is in the allowlists
+		{runnable.Credential{UID: 0, GID: 1000}, false},
+		{runnable.Credential{UID: 1000, GID: 0}, false},
+		{runnable.Credential{UID: 1000, GID: 1000, Groups: []uint32{0}}, false},
+	}
+
+	for _, test := range tests {
+		err := test.cred.Validate(allowedUIDs, allowedGIDs)
+		if test.valid && err != nil {
+			t.Errorf("expected %v to be valid, got %v", test.cred, err)
+		}
+		if !test.valid && runnable.ErrorCode(err) != runnable.EINVALID {
+			t.Errorf("expected error type %v for %v, got error %v", runnable.EINVALID, test.cred, err)
+		}
+	}
+}
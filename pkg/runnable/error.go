@@ -13,6 +13,7 @@ const (
 	EINVALID      = "invalid"      // validation failed
 	ENOTFOUND     = "not_found"    // entity does not exist
 	EUNAUTHORIZED = "unauthorized" // used is not authorized
+	EUNAVAILABLE  = "unavailable"  // try again later
 )
 
 // Error defines a standard application error.
@@ -0,0 +1,214 @@
+package runnable
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Name the server re-executes itself under to run an isolated job's command.
+// Binaries that run isolated jobs must call RunInit when started with this as os.Args[0].
+const InitCommand = "runnable-init"
+
+// The init process writes how the job's command ended to this fd, which is the first of the Cmd's ExtraFiles.
+const initStatusFd = 3
+
+// Builds the command for an isolated job. The server binary is re-executed as the init
+// process of new PID, mount, UTS and (unless hostNetwork is set) network namespaces,
+// and it then runs the job's command.
+func initCmd(hostname string, hostNetwork bool, cred *Credential, command string, args ...string) *exec.Cmd {
+	initArgs := []string{"--hostname", hostname, "--status-fd", strconv.Itoa(initStatusFd)}
+	if !hostNetwork {
+		initArgs = append(initArgs, "--loopback")
+	}
+	if cred != nil {
+		initArgs = append(initArgs,
+			"--uid", strconv.FormatUint(uint64(cred.UID), 10),
+			"--gid", strconv.FormatUint(uint64(cred.GID), 10),
+			"--groups", formatGroups(cred.Groups))
+	}
+	initArgs = append(initArgs, "--", command)
+	initArgs = append(initArgs, args...)
+
+	cmd := exec.Command("/proc/self/exe", initArgs...)
+	cmd.Args[0] = InitCommand
+
+	cloneFlags := syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWUTS
+	if !hostNetwork {
+		cloneFlags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: uintptr(cloneFlags),
+	}
+
+	return cmd
+}
+
+// Runs as PID 1 inside an isolated job's namespaces.
+// Sets up /proc, the hostname and loopback networking, then runs the job's command as the job's user,
+// forwarding signals to it and reaping any orphaned processes.
+// Exits with the command's exit code once it finishes, which kills anything left in the namespace.
+// It can't be killed by the signal that killed the command, so it writes the command's wait status to
+// --status-fd for the server to read instead.
+// Never returns.
+func RunInit() {
+	flags := flag.NewFlagSet(InitCommand, flag.ExitOnError)
+	hostname := flags.String("hostname", "", "Hostname inside the UTS namespace")
+	loopback := flags.Bool("loopback", false, "Bring up the loopback interface")
+	uid := flags.Int64("uid", -1, "User to run the command as")
+	gid := flags.Int64("gid", -1, "Group to run the command as")
+	groups := flags.String("groups", "", "Comma separated supplementary groups of the command")
+	statusFd := flags.Int("status-fd", -1, "Fd to write the command's wait status to")
+	_ = flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	if len(args) == 0 {
+		initFail("no command given")
+	}
+
+	// keep our /proc mount from propagating back to the host
+	err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+	if err != nil {
+		initFail("failed to make mounts private %v", err)
+	}
+
+	// a fresh /proc only shows processes in our PID namespace
+	err = syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+	if err != nil {
+		initFail("failed to mount /proc %v", err)
+	}
+
+	if *hostname != "" {
+		err = syscall.Sethostname([]byte(*hostname))
+		if err != nil {
+			initFail("failed to set hostname %v", err)
+		}
+	}
+
+	if *loopback {
+		err = loopbackUp()
+		if err != nil {
+			initFail("failed to bring up loopback %v", err)
+		}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if *uid >= 0 {
+		supplementary, err := parseGroups(*groups)
+		if err != nil {
+			initFail("invalid groups %v", err)
+		}
+
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: Credential{UID: uint32(*uid), GID: uint32(*gid), Groups: supplementary}.sysCredential(),
+		}
+	}
+
+	// only for us, not the command
+	if *statusFd >= 0 {
+		syscall.CloseOnExec(*statusFd)
+	}
+
+	// PID 1 ignores signals it has no handler for, so catch everything and pass it on
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals)
+
+	err = cmd.Start()
+	if err != nil {
+		initFail("failed to start command %v", err)
+	}
+
+	go func() {
+		for sig := range signals {
+			// SIGURG is used internally by the go runtime
+			if sig == syscall.SIGCHLD || sig == syscall.SIGURG {
+				continue
+			}
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	// reap everything, not just our command, as orphans in the namespace are reparented to us
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			initFail("failed to wait for command %v", err)
+		}
+
+		if pid != cmd.Process.Pid {
+			continue
+		}
+
+		if *statusFd >= 0 {
+			_, _ = syscall.Write(*statusFd, []byte(strconv.FormatUint(uint64(status), 10)))
+		}
+
+		if status.Signaled() {
+			// we cannot be killed by the same signal as PID 1, so exit like a shell would
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(status.ExitStatus())
+	}
+}
+
+// Reads the wait status the init process wrote, once it has exited. Returns false if it did not write one,
+// eg because it was killed.
+func readInitStatus(r io.Reader) (syscall.WaitStatus, bool) {
+	b, err := io.ReadAll(r)
+	if err != nil || len(b) == 0 {
+		return 0, false
+	}
+
+	status, err := strconv.ParseUint(string(b), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return syscall.WaitStatus(status), true
+}
+
+func initFail(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, InitCommand+": "+format+"\n", a...)
+	os.Exit(127)
+}
+
+// matches struct ifreq from <net/if.h>, for the SIOCSIFFLAGS ioctl
+type ifreq struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// a new network namespace only has a loopback interface, and it starts down
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	req := ifreq{
+		flags: syscall.IFF_UP | syscall.IFF_LOOPBACK | syscall.IFF_RUNNING,
+	}
+	copy(req.name[:], "lo")
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -1,9 +1,16 @@
 package runnable
 
 import (
+	"fmt"
 	"io"
+	"log"
+	"os"
 	"os/exec"
+	"path"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +25,38 @@ const (
 	Failed           = "Failed"
 	Completed        = "Completed"
 	Stopped          = "Stopped"
+	// The job was running when the server went away, so what happened to it is unknown.
+	Lost = "Lost"
+	// The job was stopped for running past its timeout or deadline.
+	TimedOut = "TimedOut"
+	// Waiting for a free slot to run in.
+	Queued = "Queued"
+	// Waiting to be run again, after an attempt that its restart policy says is to be retried.
+	Restarting = "Restarting"
+	// Every process of the job is frozen until it is resumed.
+	Paused = "Paused"
+)
+
+type Reason string
+
+// Why a job, or an attempt at running it, ended.
+const (
+	// The process exited by itself, with its exit code.
+	ReasonExited Reason = "Exited"
+	// The process was killed by a signal that the server did not send, eg SIGSEGV.
+	ReasonSignaled Reason = "Signaled"
+	// A process of the job was killed by the kernel for using more memory than the job's limit.
+	ReasonOOMKilled Reason = "OOMKilled"
+	// The job was stopped on request.
+	ReasonStopped Reason = "Stopped"
+	// The job was stopped for running past its timeout or deadline.
+	ReasonTimedOut Reason = "TimedOut"
+	// The process could not be started, eg because the command does not exist.
+	ReasonStartFailed Reason = "StartFailed"
+	// The process could not be waited for, so how it ended is unknown.
+	ReasonWaitFailed Reason = "WaitFailed"
+	// The job was running when the server went away.
+	ReasonLost Reason = "Lost"
 )
 
 type Status struct {
@@ -25,29 +64,264 @@ type Status struct {
 	StartTime time.Time
 	EndTime   time.Time
 	ExitCode  int
+	// Name of the signal that ended the process (eg SIGTERM), empty if it exited by itself.
+	Signal string
+	// Whether the process dumped core when it was killed by Signal.
+	CoreDumped bool
+	// Why the job, or its last attempt if it is to be restarted, ended. Empty until it has.
+	Reason Reason
+	// How long the job ran for, set once it has finished.
+	Elapsed time.Duration
+	// Place of a Queued job among its owner's queued jobs, 1 is started first.
+	QueuePosition int
+	// Every time the job has been run, the last one being the current one.
+	Attempts []Attempt
+	// When a Restarting job is run again.
+	NextAttempt time.Time
+	// What the last attempt to finish used, nil until one has.
+	Usage *ResourceUsage
+	// What a Running or Paused job is using right now, read from its cgroup.
+	// Nil for jobs without a cgroup, and once the job has finished.
+	Live *CgroupStats
+}
+
+// A single run of a job, which is run again if its restart policy says so.
+type Attempt struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	ExitCode   int
+	Signal     string
+	CoreDumped bool
+	Reason     Reason
+	// The attempt's logs are LogRecords records from the FirstLogRecord-th record of the job (counting from 0).
+	// LogRecords is only set once the attempt has finished.
+	FirstLogRecord int
+	LogRecords     int
+	// What the attempt used, nil until it has finished.
+	Usage *ResourceUsage
+}
+
+// Describes what a job runs, and the resources it may use.
+type JobSpec struct {
+	Command string
+	Args    []string
+	// Runs Command as a script with /bin/sh -c, with Args as its positional parameters ($1 onwards).
+	Shell  bool
+	Limits ResourceLimits
+	// Runs the command in its own PID, mount, UTS and network namespaces.
+	Isolated bool
+	// Keeps an isolated job in the host's network namespace.
+	HostNetwork bool
+	// Free form key/value pairs that jobs can be listed by.
+	Labels map[string]string
+	// Environment variables set for the job, on top of the server's own unless ClearEnv is set.
+	Env      map[string]string
+	ClearEnv bool
+	// Absolute path the job runs in, the server's working directory if empty.
+	WorkingDir string
+	// Who the job runs as, the same user as the server if nil.
+	Credential *Credential
+	// How long the job may run for, no limit if zero.
+	Timeout time.Duration
+	// When the job has to have finished by, no limit if zero.
+	Deadline time.Time
+	// Queued jobs with a higher priority start before the owner's other jobs. It has no say over
+	// which owner's job starts next, that is down to their fair share.
+	Priority int
+	// Whether the job is run again once it has finished.
+	Restart RestartPolicy
+	// The schedule that started the job, empty if it was started by hand.
+	ScheduleID string
+	// The workflow the job is part of, if any.
+	WorkflowID string
+}
+
+// Returns the job's environment, in KEY=value form.
+func (spec JobSpec) environ() []string {
+	var env []string
+	if !spec.ClearEnv {
+		env = os.Environ()
+	}
+
+	keys := make([]string, 0, len(spec.Env))
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// later entries win over the server's own
+	for _, key := range keys {
+		env = append(env, key+"="+spec.Env[key])
+	}
+	return env
+}
+
+// Returns a command that runs the job with the given ID.
+func (spec JobSpec) command(jobID string) *exec.Cmd {
+	command, args := spec.Command, spec.Args
+	if spec.Shell {
+		command = "/bin/sh"
+		args = append([]string{"-c", spec.Command, "sh"}, spec.Args...)
+	}
+
+	var cmd *exec.Cmd
+	if spec.Isolated {
+		// the init process needs to stay root to set up the namespaces, so it switches user itself
+		cmd = initCmd(jobID, spec.HostNetwork, spec.Credential, command, args...)
+	} else {
+		cmd = exec.Command(command, args...)
+		if spec.Credential != nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{
+				Credential: spec.Credential.sysCredential(),
+			}
+		}
+	}
+	cmd.Env = spec.environ()
+	cmd.Dir = spec.WorkingDir
+
+	return cmd
 }
 
 type Job struct {
 	ID        string
 	Cmd       *exec.Cmd
 	OwnerID   string
+	Spec      JobSpec
 	status    Status
-	logWriter io.WriteCloser
-	lock      sync.RWMutex
+	logWriter LogWriter
+	// serialises writes of the stdout and stderr records
+	logLock   sync.Mutex
+	cgroup    Cgroup
+	logCopies sync.WaitGroup
+	// closed and replaced every time the job writes to its logs
+	logUpdated chan struct{}
+	done       chan struct{}
+	// how many records have been written to logWriter, guarded by logLock
+	logRecords int
+	// closed when a Restarting job is stopped, so it is not run again
+	restartStopped chan struct{}
+	// whether a Paused job was frozen by its cgroup, rather than stopped with SIGSTOP
+	frozen bool
+	// whether the job's process is being spawned, which is done without the lock
+	spawning bool
+	// OOM kills in the job's cgroup when the current attempt started
+	oomKills int64
+	// read end of the pipe the init process of an isolated job reports how its command ended on
+	initStatus *os.File
+	// called every time the job's status changes
+	statusListener func(job *Job)
+	lock           sync.RWMutex
 }
 
-// Creates a new job for a given command and owner ID.
-// Job will have a state of NotStarted and a new UUID as its ID.
-func NewJob(ownerID string, command string, args ...string) (*Job, error) {
-	if command == "" {
-		return nil, &Error{
+// Records everything written to it as output of a single stream of the job.
+type jobLogWriter struct {
+	job    *Job
+	stream LogStream
+}
+
+func (w *jobLogWriter) Write(p []byte) (int, error) {
+	w.job.logLock.Lock()
+	defer w.job.logLock.Unlock()
+
+	// the payload is kept by the record, so it can't share the caller's buffer
+	payload := make([]byte, len(p))
+	copy(payload, p)
+
+	err := w.job.logWriter.WriteRecord(LogRecord{
+		Stream:  w.stream,
+		Time:    time.Now(),
+		Payload: payload,
+	})
+	w.job.notifyLogUpdated()
+	if err != nil {
+		return 0, err
+	}
+	w.job.logRecords++
+	return len(p), nil
+}
+
+// How a job is stopped.
+// The zero value kills the job straight away.
+type StopOptions struct {
+	// Signal sent to the job's processes first. SIGTERM if not set but GracePeriod is, SIGKILL otherwise.
+	Signal syscall.Signal
+	// How long to wait after sending Signal before killing the job.
+	// DefaultGracePeriod is used if not set.
+	GracePeriod time.Duration
+}
+
+const DefaultGracePeriod = 10 * time.Second
+
+// How a job that runs out of time is stopped.
+var timeoutStopOptions = StopOptions{
+	Signal: syscall.SIGTERM,
+}
+
+// Checks everything about the spec that can be, before a job is created for it.
+func (spec JobSpec) Validate() error {
+	op := "JobSpec.Validate"
+
+	if spec.Command == "" {
+		return &Error{
 			Code:    EINVALID,
-			Op:      "Job.NewJob",
+			Op:      op,
 			Message: "Command is empty.",
 		}
 	}
 
-	cmd := exec.Command(command, args...)
+	if spec.HostNetwork && !spec.Isolated {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Host networking can only be asked for by isolated jobs.",
+		}
+	}
+
+	for key, value := range spec.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") || strings.Contains(value, "\x00") {
+			return &Error{
+				Code:    EINVALID,
+				Op:      op,
+				Message: fmt.Sprintf("Invalid environment variable %q.", key),
+			}
+		}
+	}
+
+	if spec.Timeout < 0 {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Timeout can't be negative.",
+		}
+	}
+
+	if !spec.Deadline.IsZero() && !spec.Deadline.After(time.Now()) {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Deadline has already passed.",
+		}
+	}
+
+	if spec.WorkingDir != "" && !path.IsAbs(spec.WorkingDir) {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Working directory must be an absolute path.",
+		}
+	}
+
+	return spec.Restart.Validate()
+}
+
+// Creates a new job for a given spec and owner ID.
+// Job will have a state of NotStarted and a new UUID as its ID.
+func NewJob(ownerID string, spec JobSpec) (*Job, error) {
+	err := spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	jobID := uuid.NewString()
 
 	status := Status{
@@ -56,54 +330,271 @@ func NewJob(ownerID string, command string, args ...string) (*Job, error) {
 	}
 
 	return &Job{
-		ID:      jobID,
-		Cmd:     cmd,
-		OwnerID: ownerID,
-		status:  status,
+		ID:             jobID,
+		Cmd:            spec.command(jobID),
+		OwnerID:        ownerID,
+		Spec:           spec,
+		status:         status,
+		logUpdated:     make(chan struct{}),
+		done:           make(chan struct{}),
+		restartStopped: make(chan struct{}),
 	}, nil
 
 }
 
-func (job *Job) SetLogWriter(wc io.WriteCloser) {
-	job.logWriter = wc
+// Recreates a job that was run before, eg by a previous server, from what was recorded about it.
+// The job has no process, and is already done.
+func RestoreJob(jobID string, ownerID string, spec JobSpec, status Status) *Job {
+	done := make(chan struct{})
+	close(done)
+
+	return &Job{
+		ID:         jobID,
+		OwnerID:    ownerID,
+		Spec:       spec,
+		status:     status,
+		logUpdated: make(chan struct{}),
+		done:       done,
+	}
+}
+
+func (job *Job) SetLogWriter(lw LogWriter) {
+	job.logWriter = lw
+}
+
+// Sets the cgroup the job's process is started in.
+// The cgroup is deleted once the job has finished.
+func (job *Job) SetCgroup(cg Cgroup) {
+	job.cgroup = cg
+}
+
+// Sets a function that is called every time the job's status changes, eg to record it.
+// It is called without the job's lock held, so it can read the job's status.
+func (job *Job) SetStatusListener(listener func(job *Job)) {
+	job.statusListener = listener
+}
+
+func (job *Job) statusChanged() {
+	if job.statusListener != nil {
+		job.statusListener(job)
+	}
+}
+
+// Marks a job that is waiting to be started, with its place in the queue (1 is started next).
+// Returns InvalidStateError if the job is not NotStarted or Queued.
+func (job *Job) SetQueued(position int) error {
+	job.lock.Lock()
+	state := job.status.State
+	if state != NotStarted && state != Queued {
+		job.lock.Unlock()
+		return &Error{
+			Code:    EINVALID,
+			Op:      "Job.SetQueued",
+			Message: "Job is not in a NotStarted or Queued state.",
+		}
+	}
+
+	job.status.State = Queued
+	job.status.QueuePosition = position
+	job.lock.Unlock()
+
+	// only record it being queued, not every time it moves up
+	if state == NotStarted {
+		job.statusChanged()
+	}
+	return nil
+}
+
+// Returns a channel that is closed once the job has finished, and its status is final.
+func (job *Job) Done() <-chan struct{} {
+	return job.done
+}
+
+// Returns a channel that is closed the next time the job writes to its logs.
+func (job *Job) LogUpdated() <-chan struct{} {
+	job.lock.RLock()
+	defer job.lock.RUnlock()
+	return job.logUpdated
+}
+
+func (job *Job) notifyLogUpdated() {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	close(job.logUpdated)
+	job.logUpdated = make(chan struct{})
 }
 
 // get job's state
 func (job *Job) Status() Status {
+	status := job.StoredStatus()
+
+	// read without the lock, the cgroup does not go away until the job has finished
+	if job.cgroup != nil && (status.State == Running || status.State == Paused) {
+		stats, err := job.cgroup.Stats()
+		if err != nil {
+			log.Printf("Failed to read cgroup stats of job %v %v\n", job.ID, err)
+		} else {
+			status.Live = &stats
+		}
+	}
+
+	return status
+}
+
+// Returns the job's status without its live stats, which are only worth anything while it runs.
+// Unlike Status it never reads the job's cgroup.
+func (job *Job) StoredStatus() Status {
 	job.lock.RLock()
 	defer job.lock.RUnlock()
-	return job.status
+
+	// the job keeps updating its attempts, so the caller gets a copy of them
+	status := job.status
+	status.Attempts = append([]Attempt(nil), job.status.Attempts...)
+	return status
+}
+
+func (job *Job) logRecordCount() int {
+	job.logLock.Lock()
+	defer job.logLock.Unlock()
+	return job.logRecords
 }
 
-// Runs the job by calling Cmd.Run()
-// A goroutine will wait for the job to finish executing.
-// Returns InvalidStateError if the job is not in a NotStarted state.
+// Runs the job by calling Cmd.Start(), and returns as soon as the process has been spawned.
+// Goroutines copy its output to the log writer, and wait for it to finish executing, running it
+// again for as long as its restart policy says so.
+// Returns InvalidStateError if the job is not in a NotStarted or Queued state.
 func (job *Job) Start() error {
-	op := "JobService.Start"
-	if job.Status().State != NotStarted {
+	job.lock.Lock()
+	if state := job.status.State; (state != NotStarted && state != Queued) || job.spawning {
+		job.lock.Unlock()
 		return &Error{
 			Code:    EINVALID,
-			Op:      op,
-			Message: "Job is not in a NotStarted state.",
+			Op:      "JobService.Start",
+			Message: "Job is not in a NotStarted or Queued state.",
 		}
 	}
+	job.spawning = true
+	job.lock.Unlock()
+
+	firstLogRecord := job.logRecordCount()
 
-	stdout, err := job.Cmd.StdoutPipe()
+	// spawned without the lock, so the job's status can be read, and the job stopped, in the meantime
+	stdout, stderr, err := job.spawn()
 	if err != nil {
+		job.startFailed()
 		return err
 	}
 
-	stderr, err := job.Cmd.StderrPipe()
+	job.lock.Lock()
+	job.spawning = false
+	if state := job.status.State; state == NotStarted || state == Queued {
+		job.status.State = Running
+	} else {
+		job.killSpawned()
+	}
+	job.status.StartTime = time.Now()
+	job.status.QueuePosition = 0
+	job.status.Attempts = append(job.status.Attempts, Attempt{
+		StartTime:      job.status.StartTime,
+		ExitCode:       -1,
+		FirstLogRecord: firstLogRecord,
+	})
+	job.lock.Unlock()
+	job.statusChanged()
+
+	job.captureOutput(Stdout, stdout)
+	job.captureOutput(Stderr, stderr)
+
+	if deadline, ok := job.deadline(); ok {
+		go job.stopAtDeadline(deadline)
+	}
+
+	go job.run()
+
+	return nil
+}
+
+// Starts the job's Cmd in its own process group and cgroup.
+// Returns the read ends of its stdout and stderr, which the caller has to capture.
+func (job *Job) spawn() (*os.File, *os.File, error) {
+	op := "JobService.Start"
+
+	// real pipes rather than Cmd.StdoutPipe, so that Cmd.Wait does not close them while
+	// processes the job forked are still writing to them
+	stdout, stdoutWriter, err := os.Pipe()
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+		return nil, nil, err
+	}
+
+	job.Cmd.Stdout = stdoutWriter
+	job.Cmd.Stderr = stderrWriter
+
+	// the job gets its own process group, so it can be stopped along with everything it forks
+	if job.Cmd.SysProcAttr == nil {
+		job.Cmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
+	job.Cmd.SysProcAttr.Setpgid = true
 
-	logOutput := io.MultiReader(stdout, stderr)
+	var initStatusWriter *os.File
+	if job.Spec.Isolated {
+		job.initStatus, initStatusWriter, err = os.Pipe()
+		if err != nil {
+			stdout.Close()
+			stdoutWriter.Close()
+			stderr.Close()
+			stderrWriter.Close()
+			return nil, nil, err
+		}
+		job.Cmd.ExtraFiles = []*os.File{initStatusWriter}
+	}
+
+	// the process is created in the cgroup, so nothing it forks can escape the limits
+	if job.cgroup != nil {
+		job.oomKills = job.cgroupOOMKills()
+
+		dir, err := job.cgroup.Open()
+		if err != nil {
+			stdout.Close()
+			stdoutWriter.Close()
+			stderr.Close()
+			stderrWriter.Close()
+			job.closeInitStatus(initStatusWriter)
+
+			return nil, nil, &Error{
+				Code:    EINTERNAL,
+				Op:      op,
+				Message: "Failed to apply resource limits to job.",
+				Err:     err,
+			}
+		}
+		defer dir.Close()
+
+		job.Cmd.SysProcAttr.UseCgroupFD = true
+		job.Cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	}
 
 	err = job.Cmd.Start()
 
+	// the process has its own copies of the write ends now, once it (and anything it forked)
+	// has exited the read ends see EOF
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if initStatusWriter != nil {
+		initStatusWriter.Close()
+	}
+
 	if err != nil {
-		return &Error{
+		stdout.Close()
+		stderr.Close()
+		job.closeInitStatus(nil)
+		return nil, nil, &Error{
 			Code:    EINTERNAL,
 			Op:      op,
 			Message: "Failed to start job.",
@@ -111,87 +602,584 @@ func (job *Job) Start() error {
 		}
 	}
 
+	return stdout, stderr, nil
+}
+
+// Closes the pipe the init process reports on, along with the given write end of it if not nil.
+func (job *Job) closeInitStatus(writer *os.File) {
+	if writer != nil {
+		writer.Close()
+	}
+	if job.initStatus != nil {
+		job.initStatus.Close()
+		job.initStatus = nil
+	}
+}
+
+// Waits for every attempt at running the job, starting the next one for as long as the job is to be
+// restarted, and then releases everything set up for it.
+func (job *Job) run() {
+	defer close(job.done)
+	defer job.logWriter.Close()
+	defer job.deleteCgroup()
+
+	for {
+		backoff, restart := job.wait()
+		job.statusChanged()
+
+		if !restart || !job.restartAfter(backoff) {
+			return
+		}
+		job.statusChanged()
+	}
+}
+
+// Returns how many processes in the job's cgroup have been OOM killed so far, 0 if that is not known.
+func (job *Job) cgroupOOMKills() int64 {
+	kills, err := job.cgroup.OOMKills()
+	if err != nil {
+		log.Printf("Failed to read OOM kills of job %v %v\n", job.ID, err)
+		return 0
+	}
+	return kills
+}
+
+// Returns the most memory the job's cgroup has used at once, 0 if that is not known.
+func (job *Job) cgroupPeakMemory() int64 {
+	stats, err := job.cgroup.Stats()
+	if err != nil {
+		log.Printf("Failed to read peak memory of job %v %v\n", job.ID, err)
+		return 0
+	}
+	return stats.PeakMemoryBytes
+}
+
+// Marks a job that could not be started as Failed, and releases everything set up for it.
+func (job *Job) startFailed() {
+	job.deleteCgroup()
+	job.logWriter.Close()
+
 	job.lock.Lock()
-	job.status.State = Running
-	job.status.StartTime = time.Now()
+	job.spawning = false
+	job.status.State = Failed
+	job.status.Reason = ReasonStartFailed
+	job.status.EndTime = time.Now()
+	job.status.QueuePosition = 0
 	job.lock.Unlock()
+	job.statusChanged()
+
+	close(job.done)
+}
 
-	_, err = io.Copy(job.logWriter, logOutput)
+// Kills a process that was spawned while the job was being stopped. The attempt then ends like
+// any other, in the state the job was stopped in.
+// Must be called with the job's lock held.
+func (job *Job) killSpawned() {
+	err := job.signalProcessTree(syscall.SIGKILL)
 	if err != nil {
-		return err
+		log.Printf("Failed to kill job %v that was stopped while starting %v\n", job.ID, err)
 	}
+}
+
+// Copies the output to the log writer as records of the given stream, in its own goroutine,
+// so that a process writing a lot to one output is never blocked waiting for the other to be read.
+func (job *Job) captureOutput(stream LogStream, output io.ReadCloser) {
+	logWriter := &jobLogWriter{job: job, stream: stream}
+
+	job.logCopies.Add(1)
 
 	go func() {
-		err := job.wait()
-		if err != nil {
-			// task failed, set status to Failed
-			job.lock.Lock()
-			defer job.lock.Unlock()
+		defer job.logCopies.Done()
+		defer output.Close()
 
-			job.status.State = Failed
-			job.status.EndTime = time.Now()
+		_, err := io.Copy(logWriter, output)
+		if err != nil {
+			log.Printf("Failed to copy %v of job %v %v\n", stream, job.ID, err)
 		}
 	}()
-
-	return nil
 }
 
 // Waits for the wrapped process to finish before updating exit code (else there will be a race on Cmd)
-// Also updates the job state and end time.
-func (job *Job) wait() error {
-	defer job.logWriter.Close()
-
+// Anything the process left running is killed, and waited for, before the attempt is finished.
+// Also updates the job state and end time, to Restarting if it is to be run again after the returned backoff.
+func (job *Job) wait() (time.Duration, bool) {
 	var exitCode int
+	var signal string
+	var coreDumped bool
+	var failed bool
+	reason := ReasonExited
+
+	err := job.Cmd.Wait()
+	job.killProcessTree()
+
+	// nothing is left to write to the outputs, so the copies finish once they have read what is buffered
+	job.logCopies.Wait()
 
-	switch err := job.Cmd.Wait().(type) {
+	// the init process of an isolated job can't be killed by the signal its command was, so it says how it ended
+	var initStatus syscall.WaitStatus
+	initReported := false
+	if job.initStatus != nil {
+		initStatus, initReported = readInitStatus(job.initStatus)
+		job.closeInitStatus(nil)
+	}
+
+	switch err := err.(type) {
 	case nil:
 		// job completed successfully
 		exitCode = job.Cmd.ProcessState.ExitCode()
 
 	case *exec.ExitError:
-		// job exited with an exit code
+		// job exited with an exit code, or was killed by a signal
 		exitCode = err.ProcessState.ExitCode()
+		ws, ok := err.ProcessState.Sys().(syscall.WaitStatus)
+		if initReported && initStatus.Signaled() {
+			ws, ok = initStatus, true
+			exitCode = -1
+		}
+		if ok && ws.Signaled() {
+			signal = signalName(ws.Signal())
+			coreDumped = ws.CoreDump()
+			reason = ReasonSignaled
+		}
 
 	default:
 		// job failed
-		return err
+		log.Printf("Failed to wait for job %v %v\n", job.ID, err)
+		exitCode = -1
+		failed = true
+		reason = ReasonWaitFailed
+	}
+
+	// the kernel kills a single process when the cgroup runs out of memory, which need not be the
+	// job's own, but the job is only counted as OOM killed if it did not succeed anyway
+	if job.cgroup != nil && !failed && exitCode != 0 && job.cgroupOOMKills() > job.oomKills {
+		reason = ReasonOOMKilled
+	}
+
+	var usage *ResourceUsage
+	if job.Cmd.ProcessState != nil {
+		if rusage, ok := job.Cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+			usage = usageOf(rusage)
+		}
+	}
+	// the rusage of a process counts the memory of the server it was forked from, the cgroup only the job's
+	if usage != nil && job.cgroup != nil {
+		usage.PeakMemoryBytes = job.cgroupPeakMemory()
 	}
 
+	logRecords := job.logRecordCount()
+
 	job.lock.Lock()
 	defer job.lock.Unlock()
 
-	// if job has already failed or completed, do nothing as we should have already updated status before
-	if job.status.State == Failed || job.status.State == Completed {
-		return nil
-	}
+	attempt := &job.status.Attempts[len(job.status.Attempts)-1]
+	attempt.EndTime = time.Now()
+	attempt.ExitCode = exitCode
+	attempt.Signal = signal
+	attempt.CoreDumped = coreDumped
+	attempt.LogRecords = logRecords - attempt.FirstLogRecord
+	attempt.Usage = usage
 
 	job.status.ExitCode = exitCode
-	job.status.EndTime = time.Now()
-	// Update the job's state and end time.
-	// However, we should check first if it wasnt already stopped (by the user).
-	if job.status.State != Stopped {
+	job.status.Signal = signal
+	job.status.CoreDumped = coreDumped
+	job.status.Usage = attempt.Usage
+	job.status.EndTime = attempt.EndTime
+	job.status.Elapsed = job.status.EndTime.Sub(job.status.StartTime)
+
+	// the cgroup is kept for the next attempt, which would start out frozen
+	if job.frozen {
+		job.thaw()
+	}
+
+	// whatever the process died of, it was because it was stopped
+	switch job.status.State {
+	case Stopped:
+		reason = ReasonStopped
+	case TimedOut:
+		reason = ReasonTimedOut
+	}
+	attempt.Reason = reason
+	job.status.Reason = reason
+
+	// Update the job's state.
+	// However, we should check first if it wasnt already stopped (by the user, or for running out of time),
+	// in which case it is not restarted either. A Paused job can still end, eg by being killed by someone else.
+	if job.status.State != Running && job.status.State != Paused {
+		return 0, false
+	}
+
+	restarts := len(job.status.Attempts) - 1
+	backoff, restart := job.Spec.Restart.next(restarts, failed || exitCode != 0)
+	if restart {
+		job.status.State = Restarting
+		job.status.NextAttempt = attempt.EndTime.Add(backoff)
+	} else if failed {
+		job.status.State = Failed
+	} else {
 		job.status.State = Completed
 	}
 
+	return backoff, restart
+}
+
+// Waits out the backoff and then runs the job again, unless it is stopped first.
+// Returns whether the job was run again.
+func (job *Job) restartAfter(backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-job.restartStopped:
+		return false
+	}
+
+	firstLogRecord := job.logRecordCount()
+
+	job.lock.Lock()
+	if job.status.State != Restarting {
+		// stopped just as the backoff ran out
+		job.lock.Unlock()
+		return false
+	}
+
+	job.spawning = true
+	job.status.NextAttempt = time.Time{}
+	job.Cmd = job.Spec.command(job.ID)
+	job.lock.Unlock()
+
+	// spawned without the lock, like the first attempt, and stopping the job in the meantime kills it once it is
+	stdout, stderr, err := job.spawn()
+
+	job.lock.Lock()
+	job.spawning = false
+	now := time.Now()
+
+	if err != nil {
+		log.Printf("Failed to restart job %v %v\n", job.ID, err)
+		if job.status.State == Restarting {
+			job.status.State = Failed
+			job.status.Reason = ReasonStartFailed
+		}
+		job.status.ExitCode = -1
+		job.status.Signal = ""
+		job.status.CoreDumped = false
+		job.status.EndTime = now
+		job.status.Elapsed = now.Sub(job.status.StartTime)
+		job.status.Attempts = append(job.status.Attempts, Attempt{
+			StartTime:      now,
+			EndTime:        now,
+			ExitCode:       -1,
+			Reason:         ReasonStartFailed,
+			FirstLogRecord: firstLogRecord,
+		})
+		job.lock.Unlock()
+		job.statusChanged()
+		return false
+	}
+
+	if job.status.State == Restarting {
+		job.status.State = Running
+		job.status.Reason = ""
+	} else {
+		job.killSpawned()
+	}
+	job.status.ExitCode = -1
+	job.status.Signal = ""
+	job.status.CoreDumped = false
+	job.status.Attempts = append(job.status.Attempts, Attempt{
+		StartTime:      now,
+		ExitCode:       -1,
+		FirstLogRecord: firstLogRecord,
+	})
+	job.lock.Unlock()
+
+	job.captureOutput(Stdout, stdout)
+	job.captureOutput(Stderr, stderr)
+
+	return true
+}
+
+// Returns the earliest of the job's deadline, and its timeout from when it started.
+func (job *Job) deadline() (time.Time, bool) {
+	deadline := job.Spec.Deadline
+
+	if job.Spec.Timeout > 0 {
+		timeout := job.StoredStatus().StartTime.Add(job.Spec.Timeout)
+		if deadline.IsZero() || timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+
+	return deadline, !deadline.IsZero()
+}
+
+// Stops the job as TimedOut if it has not finished by the deadline.
+func (job *Job) stopAtDeadline(deadline time.Time) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-job.done:
+	case <-timer.C:
+		err := job.stop(timeoutStopOptions, TimedOut)
+		if err != nil {
+			// not Running any more means it was stopped, or finished, in the meantime
+			if ErrorCode(err) != EINVALID {
+				log.Printf("Failed to stop job %v at its deadline %v\n", job.ID, err)
+			}
+			return
+		}
+		job.statusChanged()
+	}
+}
+
+// Finishes a job that never started in the given state, and releases everything set up for it.
+// The state has to be Stopped or TimedOut, which are reasons too.
+// Must be called with the job's lock held.
+func (job *Job) cancel(state State) {
+	job.deleteCgroup()
+	job.logWriter.Close()
+
+	job.status.State = state
+	job.status.Reason = Reason(state)
+	job.status.EndTime = time.Now()
+	job.status.QueuePosition = 0
+
+	close(job.done)
+}
+
+// Kills the job if it has not finished within the grace period.
+func (job *Job) killAfter(grace time.Duration) {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+
+	select {
+	case <-job.done:
+	case <-timer.C:
+		err := job.signalProcessTree(syscall.SIGKILL)
+		if err != nil {
+			log.Printf("Failed to kill job %v after grace period %v\n", job.ID, err)
+		}
+	}
+}
+
+// Sends a signal to every process in the job's process group.
+// SIGKILL also kills anything in the job's cgroup, which catches processes that left the group.
+func (job *Job) signalProcessTree(sig syscall.Signal) error {
+	err := signalProcessGroup(job.Cmd.Process.Pid, sig)
+	if err != nil {
+		return err
+	}
+
+	if sig == syscall.SIGKILL && job.cgroup != nil {
+		return job.cgroup.Kill()
+	}
+
 	return nil
 }
 
-// Stops the job.
+// Kills every process left in the job's process group and cgroup, and waits until they are all gone.
+func (job *Job) killProcessTree() {
+	for job.processTreeAlive() {
+		err := job.signalProcessTree(syscall.SIGKILL)
+		if err != nil {
+			log.Printf("Failed to kill processes of job %v %v\n", job.ID, err)
+		}
+
+		time.Sleep(processPollInterval)
+	}
+}
+
+func (job *Job) processTreeAlive() bool {
+	if processGroupAlive(job.Cmd.Process.Pid) {
+		return true
+	}
+
+	if job.cgroup == nil {
+		return false
+	}
+
+	empty, err := job.cgroup.Empty()
+	if err != nil {
+		log.Printf("Failed to check cgroup of job %v %v\n", job.ID, err)
+		return false
+	}
+
+	return !empty
+}
+
+func (job *Job) deleteCgroup() {
+	if job.cgroup == nil {
+		return
+	}
+
+	err := job.cgroup.Delete()
+	if err != nil {
+		log.Printf("Failed to delete cgroup for job %v %v\n", job.ID, err)
+	}
+}
+
+// Freezes every process of a Running job, with the cgroup freezer or, without a cgroup, by sending
+// SIGSTOP to its process group. The job keeps its slot, and its timeout keeps running, while Paused.
 // Returns InvalidStateError if the job is not currently Running.
-func (job *Job) Stop() error {
+func (job *Job) Pause() error {
+	op := "JobService.Pause"
+
+	job.lock.Lock()
+	if job.status.State != Running {
+		job.lock.Unlock()
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Job is not in a Running state.",
+		}
+	}
+
+	err := job.freeze()
+	if err != nil {
+		job.lock.Unlock()
+		return &Error{
+			Code:    EINTERNAL,
+			Op:      op,
+			Message: "Failed to pause job.",
+			Err:     err,
+		}
+	}
+
+	job.status.State = Paused
+	job.lock.Unlock()
+
+	job.statusChanged()
+	return nil
+}
+
+// Lets the processes of a Paused job carry on from where they were paused.
+// Returns InvalidStateError if the job is not currently Paused.
+func (job *Job) Resume() error {
+	op := "JobService.Resume"
+
+	job.lock.Lock()
+	if job.status.State != Paused {
+		job.lock.Unlock()
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Job is not in a Paused state.",
+		}
+	}
+
+	err := job.thaw()
+	if err != nil {
+		job.lock.Unlock()
+		return &Error{
+			Code:    EINTERNAL,
+			Op:      op,
+			Message: "Failed to resume job.",
+			Err:     err,
+		}
+	}
+
+	job.status.State = Running
+	job.lock.Unlock()
+
+	job.statusChanged()
+	return nil
+}
+
+// Must be called with the job's lock held.
+func (job *Job) freeze() error {
+	if job.cgroup != nil {
+		err := job.cgroup.Freeze()
+		if err == nil {
+			job.frozen = true
+			return nil
+		}
+		log.Printf("Failed to freeze cgroup of job %v, stopping its process group instead %v\n", job.ID, err)
+	}
+
+	return signalProcessGroup(job.Cmd.Process.Pid, syscall.SIGSTOP)
+}
+
+// Undoes freeze, whichever way the job was frozen.
+// Must be called with the job's lock held.
+func (job *Job) thaw() error {
+	if job.frozen {
+		err := job.cgroup.Thaw()
+		if err != nil {
+			return err
+		}
+		job.frozen = false
+		return nil
+	}
+
+	return signalProcessGroup(job.Cmd.Process.Pid, syscall.SIGCONT)
+}
+
+// Stops the job, by sending its processes the signal in opts.
+// Unless that was SIGKILL, they are killed if the job has not finished after the grace period.
+// A Paused job is resumed once it has been sent the signal, so that it can act on it.
+// A Queued job is never started instead, and a Restarting one is not run again. A job that is
+// being started is killed as soon as its process has been spawned.
+// Returns InvalidStateError if the job is not currently Running, Paused, Queued or Restarting.
+func (job *Job) Stop(opts StopOptions) error {
+	err := job.stop(opts, Stopped)
+	if err != nil {
+		return err
+	}
+
+	job.statusChanged()
+	return nil
+}
+
+// Stops the job, leaving it in the given state.
+func (job *Job) stop(opts StopOptions, state State) error {
 	job.lock.Lock()
 	defer job.lock.Unlock()
 
 	op := "JobService.Stop"
-	if job.status.State != Running {
+	if job.spawning {
+		// killed as soon as it has been spawned, which is also when what was set up for it is released
+		job.status.State = state
+		job.status.Reason = Reason(state)
+		job.status.QueuePosition = 0
+		job.status.NextAttempt = time.Time{}
+		return nil
+	}
+
+	if job.status.State == Queued {
+		job.cancel(state)
+		return nil
+	}
+
+	if job.status.State == Restarting {
+		// nothing is running, the job just finishes rather than being run again
+		job.status.State = state
+		job.status.Reason = Reason(state)
+		job.status.NextAttempt = time.Time{}
+		close(job.restartStopped)
+		return nil
+	}
+
+	if job.status.State != Running && job.status.State != Paused {
 		return &Error{
 			Code:    EINVALID,
 			Op:      op,
-			Message: "Job is not in a Running state.",
+			Message: "Job is not in a Running, Paused, Queued or Restarting state.",
 		}
 	}
 
-	err := job.Cmd.Process.Kill()
+	sig := opts.Signal
+	if sig == 0 && opts.GracePeriod != 0 {
+		// asking for a grace period means the job is to be given a chance to shut down
+		sig = syscall.SIGTERM
+	}
+	if sig == 0 {
+		sig = syscall.SIGKILL
+	}
+
+	err := job.signalProcessTree(sig)
 
 	if err != nil {
 		return &Error{
@@ -202,7 +1190,23 @@ func (job *Job) Stop() error {
 		}
 	}
 
-	job.status.State = Stopped
+	if job.status.State == Paused {
+		err = job.thaw()
+		if err != nil {
+			log.Printf("Failed to resume job %v so it can be stopped %v\n", job.ID, err)
+		}
+	}
+
+	job.status.State = state
+
+	if sig != syscall.SIGKILL {
+		grace := opts.GracePeriod
+		if grace == 0 {
+			grace = DefaultGracePeriod
+		}
+
+		go job.killAfter(grace)
+	}
 
 	return nil
 }
@@ -1,22 +1,69 @@
 package runnable_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/ambardhesi/runnable/pkg/repository"
 	"github.com/ambardhesi/runnable/pkg/runnable"
 )
 
+func TestMain(m *testing.M) {
+	// isolated jobs re-execute the test binary as their init process
+	if os.Args[0] == runnable.InitCommand {
+		runnable.RunInit()
+	}
+
+	os.Exit(m.Run())
+}
+
+// waits for the job to finish, failing the test if it takes too long
+func waitForJob(t *testing.T, job *runnable.Job) {
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected job to finish")
+	}
+}
+
+// reads the payloads of the job's log records of the given stream, or of both if empty
+func readLogs(t *testing.T, lfs *repository.LocalFileSystem, jobID string, stream runnable.LogStream) []byte {
+	logFile, err := lfs.GetLogFile(jobID)
+	if err != nil {
+		t.Fatalf("expected to open log file, got %v", err)
+	}
+	defer logFile.Close()
+
+	var logs []byte
+	for {
+		record, err := logFile.ReadRecord()
+		if err == io.EOF {
+			return logs
+		}
+		if err != nil {
+			t.Fatalf("expected to read log record, got %v", err)
+		}
+		if stream == "" || record.Stream == stream {
+			logs = append(logs, record.Payload...)
+		}
+	}
+}
+
 func TestNewJob(t *testing.T) {
-	job, _ := runnable.NewJob("ownerID", "command name", "arg1", "arg2")
-	_ = os.MkdirAll("temp", 0700)
-	defer os.RemoveAll("temp")
-	logFile, _ := os.Create(path.Join("temp", job.ID))
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "command name", Args: []string{"arg1", "arg2"}})
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
 	job.SetLogWriter(logFile)
 
 	if job.ID == "" {
@@ -39,17 +86,16 @@ func TestNewJob(t *testing.T) {
 func TestStartEcho(t *testing.T) {
 	cmd := "echo"
 	args := []string{"hello world"}
-	job, _ := runnable.NewJob("ownerID", cmd, args...)
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: cmd, Args: args})
 
-	_ = os.MkdirAll("temp", 0700)
-	defer os.RemoveAll("temp")
-	logFile, _ := os.Create(path.Join("temp", job.ID))
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
 	job.SetLogWriter(logFile)
 
 	job.Cmd = fakeCmd(cmd, args...)
 	job.Start()
-
-	time.Sleep(500 * time.Millisecond)
+	waitForJob(t, job)
 
 	if ec := job.Status().ExitCode; ec != 0 {
 		t.Errorf("expected exit code %v, got %v", 0, ec)
@@ -61,20 +107,109 @@ func TestStartEcho(t *testing.T) {
 
 }
 
+func TestStartShell(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command: `printf '%s|' "$0" "$@"`,
+		Args:    []string{"one two", "", "'three'"},
+		Shell:   true,
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	// the arguments reach the script as they were given
+	expected := "sh|one two||'three'|"
+	if logs := readLogs(t, lfs, job.ID, ""); string(logs) != expected {
+		t.Errorf("expected logs %q, got %q", expected, string(logs))
+	}
+}
+
+func TestStartWithEnvAndWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command:    `echo "$FOO|$(pwd)|$(env | grep -c =)"`,
+		Shell:      true,
+		Env:        map[string]string{"FOO": "foo bar"},
+		ClearEnv:   true,
+		WorkingDir: dir,
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	// sh sets PWD itself, so FOO and PWD are all there is
+	expected := fmt.Sprintf("foo bar|%v|2\n", dir)
+	if logs := readLogs(t, lfs, job.ID, ""); string(logs) != expected {
+		t.Errorf("expected logs %q, got %q", expected, string(logs))
+	}
+}
+
+func TestInvalidEnvAndWorkingDir(t *testing.T) {
+	specs := []runnable.JobSpec{
+		{Command: "echo", Env: map[string]string{"A=B": "c"}},
+		{Command: "echo", Env: map[string]string{"": "c"}},
+		{Command: "echo", WorkingDir: "relative/dir"},
+	}
+
+	for _, spec := range specs {
+		_, err := runnable.NewJob("ownerID", spec)
+		if runnable.ErrorCode(err) != runnable.EINVALID {
+			t.Errorf("expected error type %v for %v, got error %v", runnable.EINVALID, spec, err)
+		}
+	}
+}
+
+func TestStartAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching user needs root")
+	}
+
+	for _, isolated := range []bool{false, true} {
+		job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+			Command:    "id -u; id -g; id -G",
+			Shell:      true,
+			Isolated:   isolated,
+			Credential: &runnable.Credential{UID: 65534, GID: 65534, Groups: []uint32{65533}},
+		})
+
+		lfs, _ := repository.NewLocalFileSystem("temp")
+		defer lfs.DeleteAllLogFiles()
+		logFile, _ := lfs.CreateLogFile(job.ID)
+		job.SetLogWriter(logFile)
+
+		job.Start()
+		waitForJob(t, job)
+
+		expected := "65534\n65534\n65534 65533\n"
+		if logs := readLogs(t, lfs, job.ID, ""); string(logs) != expected {
+			t.Errorf("expected isolated=%v job to run as %q, got %q", isolated, expected, string(logs))
+		}
+	}
+}
+
 func TestStartExit(t *testing.T) {
 	cmd := "exit"
 	// cmd is to exit with code 1
 	args := []string{"1"}
-	job, _ := runnable.NewJob("ownerID", cmd, args...)
-	_ = os.MkdirAll("temp", 0700)
-	defer os.RemoveAll("temp")
-	logFile, _ := os.Create(path.Join("temp", job.ID))
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: cmd, Args: args})
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
 	job.SetLogWriter(logFile)
 
 	job.Cmd = fakeCmd(cmd, args...)
 	job.Start()
-
-	time.Sleep(500 * time.Millisecond)
+	waitForJob(t, job)
 
 	if ec := job.Status().ExitCode; ec != 1 {
 		t.Errorf("expected exit code %v, got %v", 1, ec)
@@ -86,22 +221,22 @@ func TestStartExit(t *testing.T) {
 }
 
 func TestStopRunningJob(t *testing.T) {
-	job, _ := runnable.NewJob("ownerID", "sleep", "1")
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sleep", Args: []string{"1"}})
 
-	_ = os.MkdirAll("temp", 0700)
-	defer os.RemoveAll("temp")
-	logFile, _ := os.Create(path.Join("temp", job.ID))
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
 	job.SetLogWriter(logFile)
 
 	job.Cmd = fakeCmd("sleep", "1")
 	job.Start()
 
-	job.Stop()
-	// give job a chance to be stopped (or completed) before doing any assertions
-	time.Sleep(500 * time.Millisecond)
+	job.Stop(runnable.StopOptions{})
+	waitForJob(t, job)
 
-	if ec := job.Status().ExitCode; ec != 0 {
-		t.Errorf("expected exit code %v, got %v", 0, ec)
+	// killed jobs have no exit code
+	if ec := job.Status().ExitCode; ec != -1 {
+		t.Errorf("expected exit code %v, got %v", -1, ec)
 	}
 
 	if state := job.Status().State; state != runnable.Stopped {
@@ -109,20 +244,59 @@ func TestStopRunningJob(t *testing.T) {
 	}
 }
 
+func TestStartReturnsOnceSpawned(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sleep", Args: []string{"1"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Cmd = fakeCmd("sleep", "1")
+	job.Start()
+
+	if state := job.Status().State; state != runnable.Running {
+		t.Errorf("expected state %v, got %v", runnable.Running, state)
+	}
+
+	job.Stop(runnable.StopOptions{})
+	waitForJob(t, job)
+}
+
+func TestCaptureStdoutAndStderr(t *testing.T) {
+	// fills the stderr pipe before writing anything to stdout, which blocks unless both are read at once
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "head -c 1000000 /dev/zero | tr '\\0' x >&2; echo done"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	if stderr := readLogs(t, lfs, job.ID, runnable.Stderr); len(stderr) != 1000000 {
+		t.Errorf("expected %v bytes of stderr, got %v", 1000000, len(stderr))
+	}
+
+	if stdout := readLogs(t, lfs, job.ID, runnable.Stdout); string(stdout) != "done\n" {
+		t.Errorf("expected stdout %q, got %q", "done\n", string(stdout))
+	}
+}
+
 func TestStopCompletedJob(t *testing.T) {
-	job, _ := runnable.NewJob("ownerID", "exit", "0")
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "exit", Args: []string{"0"}})
 
-	_ = os.MkdirAll("temp", 0700)
-	defer os.RemoveAll("temp")
-	logFile, _ := os.Create(path.Join("temp", job.ID))
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
 	job.SetLogWriter(logFile)
 
 	job.Cmd = fakeCmd("exit", "0")
 	job.Start()
 
-	// give job a chance to complete before attempting to stop
-	time.Sleep(200 * time.Millisecond)
-	job.Stop()
+	waitForJob(t, job)
+	job.Stop(runnable.StopOptions{})
 
 	if ec := job.Status().ExitCode; ec != 0 {
 		t.Errorf("expected exit code %v, got %v", 0, ec)
@@ -133,6 +307,613 @@ func TestStopCompletedJob(t *testing.T) {
 	}
 }
 
+func TestIsolatedJob(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces needs root")
+	}
+
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command:  "sh",
+		Args:     []string{"-c", "echo init=$(head -c 13 /proc/1/cmdline) host=$(cat /proc/sys/kernel/hostname) ifaces=$(grep -c : /proc/net/dev)"},
+		Isolated: true,
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	if state := job.Status().State; state != runnable.Completed {
+		t.Errorf("expected state %v, got %v", runnable.Completed, state)
+	}
+
+	logs := readLogs(t, lfs, job.ID, "")
+
+	// the job's /proc only shows its own processes, and its network namespace only has loopback
+	expected := fmt.Sprintf("init=%v host=%v ifaces=1", runnable.InitCommand, job.ID)
+	if !strings.Contains(string(logs), expected) {
+		t.Errorf("expected logs to contain %v, got %v", expected, string(logs))
+	}
+}
+
+func TestIsolatedJobSignaled(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces needs root")
+	}
+
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command:  "sh",
+		Args:     []string{"-c", "kill -TERM $$"},
+		Isolated: true,
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	// the init process exits rather than being killed, but says what its command was killed by
+	status := job.Status()
+	if status.Reason != runnable.ReasonSignaled || status.Signal != "SIGTERM" || status.ExitCode != -1 {
+		t.Errorf("expected job to be killed by %v, got %+v", "SIGTERM", status)
+	}
+}
+
+func TestHostNetworkNeedsIsolation(t *testing.T) {
+	_, err := runnable.NewJob("ownerID", runnable.JobSpec{Command: "echo", HostNetwork: true})
+
+	if runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v, got error %v", runnable.EINVALID, err)
+	}
+}
+
+// Starts processes in the cgroup the test is running in, so that the limits of the test apply to them.
+type fakeCgroup struct {
+	dir      string
+	opened   bool
+	deleted  bool
+	oomKills int64
+	lock     sync.Mutex
+}
+
+// Skips the test if it is not running in a cgroup v2 hierarchy.
+func newFakeCgroup(t *testing.T) *fakeCgroup {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		t.Skipf("no cgroups %v", err)
+	}
+
+	// the v2 hierarchy is the "0::/path" line, and can be mounted anywhere
+	var cgroupPath string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			cgroupPath = strings.TrimPrefix(line, "0::")
+		}
+	}
+
+	mounts, _ := os.ReadFile("/proc/self/mounts")
+	for _, line := range strings.Split(string(mounts), "\n") {
+		fields := strings.Fields(line)
+		if cgroupPath != "" && len(fields) > 2 && fields[2] == "cgroup2" {
+			return &fakeCgroup{dir: path.Join(fields[1], cgroupPath)}
+		}
+	}
+
+	t.Skip("no cgroup v2 hierarchy")
+	return nil
+}
+
+func (cg *fakeCgroup) Open() (*os.File, error) {
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+	cg.opened = true
+	return os.Open(cg.dir)
+}
+
+func (cg *fakeCgroup) Kill() error {
+	return nil
+}
+
+func (cg *fakeCgroup) Empty() (bool, error) {
+	return true, nil
+}
+
+func (cg *fakeCgroup) Freeze() error {
+	return errors.New("no freezer")
+}
+
+func (cg *fakeCgroup) Thaw() error {
+	return errors.New("no freezer")
+}
+
+func (cg *fakeCgroup) Stats() (runnable.CgroupStats, error) {
+	return runnable.CgroupStats{MemoryBytes: 1024, PeakMemoryBytes: 2048}, nil
+}
+
+func (cg *fakeCgroup) OOMKills() (int64, error) {
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+	return cg.oomKills, nil
+}
+
+func (cg *fakeCgroup) Delete() error {
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+	cg.deleted = true
+	return nil
+}
+
+func TestStartInCgroup(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "exit", Args: []string{"0"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	cg := newFakeCgroup(t)
+	job.SetCgroup(cg)
+
+	job.Cmd = fakeCmd("exit", "0")
+	if err := job.Start(); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	waitForJob(t, job)
+
+	cg.lock.Lock()
+	defer cg.lock.Unlock()
+
+	if !cg.opened {
+		t.Errorf("expected process to be started in the cgroup")
+	}
+
+	if !cg.deleted {
+		t.Errorf("expected cgroup to be deleted once job finished")
+	}
+}
+
+func TestResourceUsage(t *testing.T) {
+	// busy for long enough to use some CPU
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command: "i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done; sleep 0.3",
+		Shell:   true,
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+	job.SetCgroup(newFakeCgroup(t))
+
+	job.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	status := job.Status()
+	if status.Live == nil || status.Live.MemoryBytes != 1024 {
+		t.Errorf("expected live stats of the running job to be read from its cgroup, got %v", status.Live)
+	}
+	if status.Usage != nil {
+		t.Errorf("expected no usage before the job has finished, got %v", status.Usage)
+	}
+
+	waitForJob(t, job)
+
+	status = job.Status()
+	if status.Live != nil {
+		t.Errorf("expected no live stats once the job has finished, got %v", status.Live)
+	}
+
+	usage := status.Usage
+	if usage == nil {
+		t.Fatalf("expected usage once the job has finished")
+	}
+	if usage.UserCPU+usage.SystemCPU <= 0 || usage.VoluntaryContextSwitches <= 0 {
+		t.Errorf("expected the job to have used CPU, and waited, got %+v", *usage)
+	}
+	// from the cgroup, rather than from the rusage, which counts the server's memory too
+	if usage.PeakMemoryBytes != 2048 {
+		t.Errorf("expected the peak memory of the job's cgroup, got %v", usage.PeakMemoryBytes)
+	}
+	if status.Attempts[0].Usage != usage {
+		t.Errorf("expected the attempt to have the same usage as the job")
+	}
+}
+
+func TestTerminationReasons(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		script     string
+		oomKill    bool
+		stop       bool
+		reason     runnable.Reason
+		signal     string
+		coreDumped bool
+	}{
+		{name: "exit", script: "exit 3", reason: runnable.ReasonExited},
+		{name: "signal", script: "kill -TERM $$", reason: runnable.ReasonSignaled, signal: "SIGTERM"},
+		{name: "core dump", script: "ulimit -c unlimited; cd $(mktemp -d); kill -QUIT $$", reason: runnable.ReasonSignaled, signal: "SIGQUIT", coreDumped: true},
+		{name: "oom kill", script: "sleep 0.3; kill -KILL $$", oomKill: true, reason: runnable.ReasonOOMKilled, signal: "SIGKILL"},
+		{name: "oom kill of a child", script: "sleep 0.3", oomKill: true, reason: runnable.ReasonExited},
+		{name: "stop", script: "sleep 10", stop: true, reason: runnable.ReasonStopped, signal: "SIGKILL"},
+	} {
+		job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: test.script, Shell: true})
+
+		lfs, _ := repository.NewLocalFileSystem("temp")
+		logFile, _ := lfs.CreateLogFile(job.ID)
+		job.SetLogWriter(logFile)
+		cg := newFakeCgroup(t)
+		job.SetCgroup(cg)
+
+		job.Start()
+		if test.oomKill {
+			time.Sleep(100 * time.Millisecond)
+			cg.lock.Lock()
+			cg.oomKills++
+			cg.lock.Unlock()
+		}
+		if test.stop {
+			time.Sleep(100 * time.Millisecond)
+			job.Stop(runnable.StopOptions{})
+		}
+		waitForJob(t, job)
+		lfs.DeleteAllLogFiles()
+
+		status := job.Status()
+		if status.Reason != test.reason || status.Signal != test.signal {
+			t.Errorf("%v: expected reason %v and signal %q, got %v and %q", test.name, test.reason, test.signal, status.Reason, status.Signal)
+		}
+		// whether the core is dumped is up to the system's core pattern, it can only be checked if it was
+		if test.coreDumped && status.CoreDumped {
+			if !status.Attempts[0].CoreDumped {
+				t.Errorf("%v: expected the attempt to have dumped core", test.name)
+			}
+		} else if status.CoreDumped {
+			t.Errorf("%v: did not expect core to be dumped", test.name)
+		}
+		if status.Attempts[0].Reason != status.Reason {
+			t.Errorf("%v: expected the attempt to have reason %v, got %v", test.name, status.Reason, status.Attempts[0].Reason)
+		}
+	}
+}
+
+func TestStopWithSignal(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sleep", Args: []string{"1"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Cmd = fakeCmd("sleep", "1")
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	job.Stop(runnable.StopOptions{Signal: syscall.SIGTERM})
+	waitForJob(t, job)
+
+	if state := job.Status().State; state != runnable.Stopped {
+		t.Errorf("expected state %v, got %v", runnable.Stopped, state)
+	}
+
+	if sig := job.Status().Signal; sig != "SIGTERM" {
+		t.Errorf("expected job to be ended by %v, got %v", "SIGTERM", sig)
+	}
+}
+
+func TestStopGracefully(t *testing.T) {
+	// exits cleanly on SIGTERM
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "trap 'exit 0' TERM; sleep 10 & wait"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	job.Stop(runnable.StopOptions{Signal: syscall.SIGTERM, GracePeriod: 5 * time.Second})
+	waitForJob(t, job)
+
+	status := job.Status()
+	if status.State != runnable.Stopped {
+		t.Errorf("expected state %v, got %v", runnable.Stopped, status.State)
+	}
+
+	if status.ExitCode != 0 || status.Signal != "" {
+		t.Errorf("expected job to exit by itself, got exit code %v and signal %v", status.ExitCode, status.Signal)
+	}
+}
+
+func TestStopWithGracePeriodOnly(t *testing.T) {
+	// exits cleanly on SIGTERM
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "trap 'exit 0' TERM; sleep 10 & wait"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	// a grace period without a signal sends SIGTERM first, rather than killing the job
+	job.Stop(runnable.StopOptions{GracePeriod: 5 * time.Second})
+	waitForJob(t, job)
+
+	if status := job.Status(); status.State != runnable.Stopped || status.ExitCode != 0 || status.Signal != "" {
+		t.Errorf("expected job to exit by itself, got exit code %v and signal %v", status.ExitCode, status.Signal)
+	}
+}
+
+func TestStopEscalatesAfterGracePeriod(t *testing.T) {
+	// ignores SIGTERM
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "trap '' TERM; sleep 10"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	job.Stop(runnable.StopOptions{Signal: syscall.SIGTERM, GracePeriod: 200 * time.Millisecond})
+
+	select {
+	case <-job.Done():
+		t.Fatalf("expected job to still be running during its grace period")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	select {
+	case <-job.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected job to be killed after its grace period")
+	}
+
+	if sig := job.Status().Signal; sig != "SIGKILL" {
+		t.Errorf("expected job to be ended by %v, got %v", "SIGKILL", sig)
+	}
+}
+
+// Returns the state of the process as shown by /proc, eg R for running or T for stopped.
+func processState(t *testing.T, pid int) string {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		t.Fatalf("expected to read the state of process %v, got %v", pid, err)
+	}
+
+	// the command name in brackets can contain spaces, the state comes right after it
+	fields := strings.Fields(string(b[strings.LastIndex(string(b), ")")+1:]))
+	return fields[0]
+}
+
+func TestPauseAndResume(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sleep", Args: []string{"10"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	// without a freezer, the job falls back to SIGSTOP
+	job.SetCgroup(newFakeCgroup(t))
+
+	if err := job.Pause(); runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v pausing a job that is not running, got %v", runnable.EINVALID, err)
+	}
+
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+	pid := job.Cmd.Process.Pid
+
+	if err := job.Pause(); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if state := job.Status().State; state != runnable.Paused {
+		t.Errorf("expected state %v, got %v", runnable.Paused, state)
+	}
+	if state := processState(t, pid); state != "T" {
+		t.Errorf("expected process to be stopped, got state %v", state)
+	}
+	if err := job.Pause(); runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v pausing a paused job, got %v", runnable.EINVALID, err)
+	}
+
+	if err := job.Resume(); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if state := job.Status().State; state != runnable.Running {
+		t.Errorf("expected state %v, got %v", runnable.Running, state)
+	}
+	if state := processState(t, pid); state == "T" {
+		t.Errorf("expected process to carry on running")
+	}
+	if err := job.Resume(); runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v resuming a running job, got %v", runnable.EINVALID, err)
+	}
+
+	job.Stop(runnable.StopOptions{})
+	waitForJob(t, job)
+}
+
+func TestStopPausedJob(t *testing.T) {
+	// exits cleanly on SIGTERM, which it can only act on once resumed
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "trap 'exit 0' TERM; sleep 10 & wait"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+	job.Pause()
+
+	if err := job.Stop(runnable.StopOptions{Signal: syscall.SIGTERM, GracePeriod: 5 * time.Second}); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	select {
+	case <-job.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected paused job to act on the signal")
+	}
+
+	status := job.Status()
+	if status.State != runnable.Stopped || status.ExitCode != 0 {
+		t.Errorf("expected job to exit by itself and be %v, got %v with exit code %v", runnable.Stopped, status.State, status.ExitCode)
+	}
+}
+
+func TestTimeoutAndDeadline(t *testing.T) {
+	for _, name := range []string{"timeout", "deadline"} {
+		spec := runnable.JobSpec{Command: "sleep", Args: []string{"10"}}
+		if name == "timeout" {
+			spec.Timeout = 200 * time.Millisecond
+		} else {
+			spec.Deadline = time.Now().Add(200 * time.Millisecond)
+		}
+
+		job, _ := runnable.NewJob("ownerID", spec)
+
+		lfs, _ := repository.NewLocalFileSystem("temp")
+		defer lfs.DeleteAllLogFiles()
+		logFile, _ := lfs.CreateLogFile(job.ID)
+		job.SetLogWriter(logFile)
+
+		job.Start()
+		waitForJob(t, job)
+
+		// stopped like Job.Stop would, sleep exits on SIGTERM without needing to be killed
+		status := job.Status()
+		if status.State != runnable.TimedOut || status.Signal != "SIGTERM" {
+			t.Errorf("%v: expected state %v by SIGTERM, got %v by %v", name, runnable.TimedOut, status.State, status.Signal)
+		}
+
+		// the deadline was set a little before the job started
+		if status.Elapsed < 150*time.Millisecond || status.Elapsed > 2*time.Second {
+			t.Errorf("%v: expected job to run for about 200ms, got %v", name, status.Elapsed)
+		}
+	}
+}
+
+func TestFinishBeforeTimeout(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "echo", Timeout: time.Second})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	status := job.Status()
+	if status.State != runnable.Completed || status.Elapsed <= 0 {
+		t.Errorf("expected state %v with elapsed time, got %v after %v", runnable.Completed, status.State, status.Elapsed)
+	}
+}
+
+func TestDeadlineAlreadyPassed(t *testing.T) {
+	_, err := runnable.NewJob("ownerID", runnable.JobSpec{Command: "echo", Deadline: time.Now().Add(-time.Second)})
+
+	if runnable.ErrorCode(err) != runnable.EINVALID {
+		t.Errorf("expected error type %v, got error %v", runnable.EINVALID, err)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, s := range []string{"SIGTERM", "TERM", "term", "15"} {
+		sig, err := runnable.ParseSignal(s)
+		if err != nil || sig != syscall.SIGTERM {
+			t.Errorf("expected %v to parse as SIGTERM, got %v %v", s, sig, err)
+		}
+	}
+
+	for _, s := range []string{"SIGFOO", "", "999"} {
+		_, err := runnable.ParseSignal(s)
+		if runnable.ErrorCode(err) != runnable.EINVALID {
+			t.Errorf("expected error type %v for %q, got error %v", runnable.EINVALID, s, err)
+		}
+	}
+}
+
+// returns whether the process is gone, zombies count as gone
+func processGone(pid string) bool {
+	b, err := os.ReadFile(path.Join("/proc", pid, "stat"))
+	if err != nil {
+		return true
+	}
+
+	stat := string(b)
+	return strings.Fields(stat[strings.LastIndex(stat, ")")+1:])[0] == "Z"
+}
+
+func TestStopKillsProcessTree(t *testing.T) {
+	// the shell forks a sleep, and prints its pid
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "sleep 10 & echo $!; wait"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	time.Sleep(200 * time.Millisecond)
+
+	logs := readLogs(t, lfs, job.ID, "")
+	pid := strings.TrimSpace(string(logs))
+
+	if err := job.Stop(runnable.StopOptions{}); err != nil {
+		t.Errorf("expected no errors, got %v", err)
+	}
+	waitForJob(t, job)
+
+	if state := job.Status().State; state != runnable.Stopped {
+		t.Errorf("expected state %v, got %v", runnable.Stopped, state)
+	}
+
+	if !processGone(pid) {
+		t.Errorf("expected forked process %v to be killed", pid)
+	}
+}
+
+func TestWaitKillsLeftoverProcesses(t *testing.T) {
+	// the shell leaves a sleep running in the background, that does not hold on to the log pipe
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{Command: "sh", Args: []string{"-c", "sleep 10 > /dev/null 2>&1 & echo $!"}})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	if state := job.Status().State; state != runnable.Completed {
+		t.Errorf("expected state %v, got %v", runnable.Completed, state)
+	}
+
+	logs := readLogs(t, lfs, job.ID, "")
+	pid := strings.TrimSpace(string(logs))
+
+	if !processGone(pid) {
+		t.Errorf("expected leftover process %v to be killed", pid)
+	}
+}
+
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -0,0 +1,113 @@
+package runnable
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// matches a block device number, eg 8:0
+var deviceRegexp = regexp.MustCompile(`^[0-9]+:[0-9]+$`)
+
+// Resource limits applied to a job through its cgroup.
+// A zero value for a field means that resource is not limited.
+type ResourceLimits struct {
+	// CPU time the job may use, in thousandths of a CPU (eg 500 is half a CPU).
+	CPUMillis int64
+	// Maximum memory the job may use, in bytes.
+	MemoryBytes int64
+	// IO limits, one per block device.
+	IO []IOLimit
+}
+
+// IO limits for a single block device.
+type IOLimit struct {
+	// Device number in the major:minor format, eg 8:0
+	Device    string
+	ReadBPS   int64
+	WriteBPS  int64
+	ReadIOPS  int64
+	WriteIOPS int64
+}
+
+func (limits ResourceLimits) IsZero() bool {
+	return limits.CPUMillis == 0 && limits.MemoryBytes == 0 && len(limits.IO) == 0
+}
+
+// Returns a copy of the limits, with every unset field taken from defaults.
+func (limits ResourceLimits) WithDefaults(defaults ResourceLimits) ResourceLimits {
+	if limits.CPUMillis == 0 {
+		limits.CPUMillis = defaults.CPUMillis
+	}
+	if limits.MemoryBytes == 0 {
+		limits.MemoryBytes = defaults.MemoryBytes
+	}
+	if len(limits.IO) == 0 {
+		limits.IO = defaults.IO
+	}
+	return limits
+}
+
+// Checks that the limits are well formed and do not exceed max.
+// A zero field in max means there is no maximum for that resource.
+func (limits ResourceLimits) Validate(max ResourceLimits) error {
+	op := "ResourceLimits.Validate"
+
+	if err := checkLimit(op, "cpuMillis", limits.CPUMillis, max.CPUMillis); err != nil {
+		return err
+	}
+	if err := checkLimit(op, "memoryBytes", limits.MemoryBytes, max.MemoryBytes); err != nil {
+		return err
+	}
+
+	// IO maximums are not per device, the first entry (if any) applies to every device
+	var maxIO IOLimit
+	if len(max.IO) > 0 {
+		maxIO = max.IO[0]
+	}
+
+	for _, io := range limits.IO {
+		if !deviceRegexp.MatchString(io.Device) {
+			return &Error{
+				Code:    EINVALID,
+				Op:      op,
+				Message: fmt.Sprintf("Invalid device %q, expected major:minor.", io.Device),
+			}
+		}
+
+		for _, l := range []struct {
+			name       string
+			value, max int64
+		}{
+			{"readBps", io.ReadBPS, maxIO.ReadBPS},
+			{"writeBps", io.WriteBPS, maxIO.WriteBPS},
+			{"readIops", io.ReadIOPS, maxIO.ReadIOPS},
+			{"writeIops", io.WriteIOPS, maxIO.WriteIOPS},
+		} {
+			if err := checkLimit(op, l.name, l.value, l.max); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkLimit(op string, name string, value int64, max int64) error {
+	if value < 0 {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("%v must not be negative.", name),
+		}
+	}
+
+	if max != 0 && (value == 0 || value > max) {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("%v must be set and at most %v.", name, max),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package runnable_test
+
+import (
+	"testing"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestLimitsWithDefaults(t *testing.T) {
+	defaults := runnable.ResourceLimits{
+		CPUMillis:   1000,
+		MemoryBytes: 2048,
+		IO:          []runnable.IOLimit{{Device: "8:0", ReadBPS: 10}},
+	}
+
+	limits := runnable.ResourceLimits{MemoryBytes: 1024}.WithDefaults(defaults)
+
+	if limits.CPUMillis != 1000 {
+		t.Errorf("expected default cpuMillis %v, got %v", 1000, limits.CPUMillis)
+	}
+
+	if limits.MemoryBytes != 1024 {
+		t.Errorf("expected memoryBytes %v, got %v", 1024, limits.MemoryBytes)
+	}
+
+	if len(limits.IO) != 1 || limits.IO[0].Device != "8:0" {
+		t.Errorf("expected default io limits, got %v", limits.IO)
+	}
+}
+
+func TestLimitsValidate(t *testing.T) {
+	max := runnable.ResourceLimits{
+		MemoryBytes: 1024,
+		IO:          []runnable.IOLimit{{ReadBPS: 100}},
+	}
+
+	tests := []struct {
+		name   string
+		limits runnable.ResourceLimits
+		valid  bool
+	}{
+		{"within max", runnable.ResourceLimits{CPUMillis: 5000, MemoryBytes: 1024}, true},
+		{"over max", runnable.ResourceLimits{MemoryBytes: 2048}, false},
+		{"unset with max", runnable.ResourceLimits{CPUMillis: 500}, false},
+		{"negative", runnable.ResourceLimits{CPUMillis: -1, MemoryBytes: 1}, false},
+		{"bad device", runnable.ResourceLimits{MemoryBytes: 1, IO: []runnable.IOLimit{{Device: "sda", ReadBPS: 1}}}, false},
+		{"io over max", runnable.ResourceLimits{MemoryBytes: 1, IO: []runnable.IOLimit{{Device: "8:0", ReadBPS: 101}}}, false},
+		{"io within max", runnable.ResourceLimits{MemoryBytes: 1, IO: []runnable.IOLimit{{Device: "8:0", ReadBPS: 100}}}, true},
+	}
+
+	for _, test := range tests {
+		err := test.limits.Validate(max)
+		if test.valid && err != nil {
+			t.Errorf("%v: expected no errors, got %v", test.name, err)
+		}
+		if !test.valid && runnable.ErrorCode(err) != runnable.EINVALID {
+			t.Errorf("%v: expected error type %v, got error %v", test.name, runnable.EINVALID, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package runnable
+
+import (
+	"strings"
+	"time"
+)
+
+// Picks out jobs, every field that is set has to match.
+type JobFilter struct {
+	OwnerID string
+	// Matches jobs in any of these states.
+	States []State
+	// Matches jobs started at or after this time.
+	StartedAfter time.Time
+	// Matches jobs started before this time.
+	StartedBefore time.Time
+	CommandPrefix string
+	// Matches jobs that have all of these labels, with the same values.
+	Labels map[string]string
+	// Matches jobs started by this schedule.
+	ScheduleID string
+	// Matches jobs of this workflow.
+	WorkflowID string
+}
+
+func (filter JobFilter) Matches(job *Job) bool {
+	if filter.OwnerID != "" && job.OwnerID != filter.OwnerID {
+		return false
+	}
+
+	status := job.StoredStatus()
+	if len(filter.States) > 0 && !hasState(filter.States, status.State) {
+		return false
+	}
+
+	if !filter.StartedAfter.IsZero() && status.StartTime.Before(filter.StartedAfter) {
+		return false
+	}
+
+	if !filter.StartedBefore.IsZero() && !status.StartTime.Before(filter.StartedBefore) {
+		return false
+	}
+
+	if !strings.HasPrefix(job.Spec.Command, filter.CommandPrefix) {
+		return false
+	}
+
+	if filter.ScheduleID != "" && job.Spec.ScheduleID != filter.ScheduleID {
+		return false
+	}
+
+	if filter.WorkflowID != "" && job.Spec.WorkflowID != filter.WorkflowID {
+		return false
+	}
+
+	for key, value := range filter.Labels {
+		if v, ok := job.Spec.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// How jobs are listed.
+type ListOptions struct {
+	Filter JobFilter
+	// Most jobs returned at once, DefaultListLimit if not set.
+	Limit int
+	// Where to carry on from, as returned with the previous page. Starts from the beginning if empty.
+	Cursor string
+	// Lists the oldest jobs first, rather than the newest.
+	Ascending bool
+}
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 1000
+)
+
+// A page of listed jobs, sorted by start time.
+type JobPage struct {
+	Jobs []*Job
+	// Gets the next page when passed back in ListOptions, empty if this was the last page.
+	NextCursor string
+}
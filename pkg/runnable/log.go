@@ -0,0 +1,32 @@
+package runnable
+
+import (
+	"time"
+)
+
+type LogStream string
+
+// The outputs of a job.
+const (
+	Stdout LogStream = "stdout"
+	Stderr LogStream = "stderr"
+)
+
+// A chunk of a job's output, as it was captured.
+type LogRecord struct {
+	Stream  LogStream
+	Time    time.Time
+	Payload []byte
+}
+
+// How a job's logs are read.
+type LogOptions struct {
+	// Keeps reading as the job writes more logs, until it has finished.
+	Follow bool
+	// Only reads logs from this stream, or from both if empty.
+	Stream LogStream
+	// Prefixes every line with the time it was captured.
+	Timestamps bool
+	// Only reads the logs of this attempt at running the job (1 is the first), or of every attempt if zero.
+	Attempt int
+}
@@ -0,0 +1,74 @@
+package runnable
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// how often to check whether a job's processes are gone
+const processPollInterval = 10 * time.Millisecond
+
+// Sends a signal to every process in the process group.
+// A group that is already gone is not an error.
+func signalProcessGroup(pgid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pgid, sig)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+// Returns whether any process in the process group is still running.
+// Zombies do not count, they are dead and only waiting for whoever inherited them to reap them.
+func processGroupAlive(pgid int) bool {
+	// cheap check first, this only fails once every process in the group (including zombies) is gone
+	if syscall.Kill(-pgid, 0) == syscall.ESRCH {
+		return false
+	}
+
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		// assume the worst, the caller will check again
+		return true
+	}
+
+	for _, dir := range dirs {
+		if _, err := strconv.Atoi(dir.Name()); err != nil {
+			continue
+		}
+
+		state, group, ok := readProcessStat(dir.Name())
+		if ok && group == pgid && state != "Z" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reads the state and process group of a process from /proc/[pid]/stat
+func readProcessStat(pid string) (string, int, bool) {
+	b, err := os.ReadFile(path.Join("/proc", pid, "stat"))
+	if err != nil {
+		return "", 0, false
+	}
+
+	// the command name can contain spaces and brackets, so skip past its closing bracket
+	// what is left is "state ppid pgrp ..."
+	stat := string(b)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 3 {
+		return "", 0, false
+	}
+
+	group, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return fields[0], group, true
+}
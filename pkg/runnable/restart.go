@@ -0,0 +1,95 @@
+package runnable
+
+import (
+	"fmt"
+	"time"
+)
+
+type RestartMode string
+
+// When a job that has finished is run again.
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure"
+	RestartAlways    RestartMode = "always"
+)
+
+const (
+	DefaultRestartBackoff    = time.Second
+	DefaultMaxRestartBackoff = 5 * time.Minute
+)
+
+// Whether, and how, a job is run again once it has finished. Jobs that are stopped, or run out of time,
+// are never restarted. The zero value never restarts the job.
+type RestartPolicy struct {
+	// RestartNever if empty.
+	Mode RestartMode
+	// Most times the job is restarted, no limit if zero.
+	MaxRetries int
+	// How long to wait before the first restart, doubled for every one after that.
+	// DefaultRestartBackoff is used if not set.
+	Backoff time.Duration
+	// Longest to wait before a restart. DefaultMaxRestartBackoff, or Backoff if that is longer, is used if not set.
+	MaxBackoff time.Duration
+}
+
+// Returns EINVALID if the policy is not one that can be followed.
+func (policy RestartPolicy) Validate() error {
+	op := "RestartPolicy.Validate"
+
+	switch policy.Mode {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("Invalid restart policy %q, must be never, on-failure or always.", policy.Mode),
+		}
+	}
+
+	if policy.MaxRetries < 0 || policy.Backoff < 0 || policy.MaxBackoff < 0 {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Restart retries and backoffs can't be negative.",
+		}
+	}
+
+	return nil
+}
+
+// Returns whether a job that has been restarted the given number of times is to be run again, after an
+// attempt that failed or not, and how long to wait before doing so.
+func (policy RestartPolicy) next(restarts int, failed bool) (time.Duration, bool) {
+	switch {
+	case policy.Mode == RestartAlways:
+	case policy.Mode == RestartOnFailure && failed:
+	default:
+		return 0, false
+	}
+
+	if policy.MaxRetries > 0 && restarts >= policy.MaxRetries {
+		return 0, false
+	}
+
+	backoff := policy.Backoff
+	if backoff == 0 {
+		backoff = DefaultRestartBackoff
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxRestartBackoff
+		if backoff > maxBackoff {
+			maxBackoff = backoff
+		}
+	}
+
+	for i := 0; i < restarts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff, true
+}
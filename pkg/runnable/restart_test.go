@@ -0,0 +1,207 @@
+package runnable_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestRestartOnFailure(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command: "echo failing; exit 3",
+		Shell:   true,
+		Restart: runnable.RestartPolicy{
+			Mode:       runnable.RestartOnFailure,
+			MaxRetries: 2,
+			Backoff:    20 * time.Millisecond,
+		},
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	status := job.Status()
+	if status.State != runnable.Completed || status.ExitCode != 3 {
+		t.Errorf("expected job to be %v with exit code 3, got %v with %v", runnable.Completed, status.State, status.ExitCode)
+	}
+
+	// the first run and two retries
+	if len(status.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %v", len(status.Attempts))
+	}
+
+	for i, attempt := range status.Attempts {
+		if attempt.ExitCode != 3 || attempt.FirstLogRecord != i || attempt.LogRecords != 1 {
+			t.Errorf("unexpected attempt %v %+v", i+1, attempt)
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		// the backoff doubles every time
+		backoff := 20 * time.Millisecond << (i - 1)
+		if gap := attempt.StartTime.Sub(status.Attempts[i-1].EndTime); gap < backoff {
+			t.Errorf("expected attempt %v to start at least %v after the one before, got %v", i+1, backoff, gap)
+		}
+	}
+
+	if logs := string(readLogs(t, lfs, job.ID, runnable.Stdout)); logs != strings.Repeat("failing\n", 3) {
+		t.Errorf("expected the logs of every attempt, got %q", logs)
+	}
+}
+
+func TestRestartOnFailureUntilSuccess(t *testing.T) {
+	dir := t.TempDir()
+
+	// fails the first time it is run only
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command: `if [ -e "$1" ]; then echo done; else touch "$1"; exit 1; fi`,
+		Args:    []string{path.Join(dir, "ran")},
+		Shell:   true,
+		Restart: runnable.RestartPolicy{
+			Mode:    runnable.RestartOnFailure,
+			Backoff: time.Millisecond,
+		},
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+	waitForJob(t, job)
+
+	status := job.Status()
+	if status.State != runnable.Completed || status.ExitCode != 0 || len(status.Attempts) != 2 {
+		t.Errorf("expected job to be %v with exit code 0 after 2 attempts, got %+v", runnable.Completed, status)
+	}
+
+	if first := status.Attempts[0]; first.ExitCode != 1 || first.LogRecords != 0 {
+		t.Errorf("unexpected first attempt %+v", first)
+	}
+	if second := status.Attempts[1]; second.ExitCode != 0 || second.FirstLogRecord != 0 || second.LogRecords != 1 {
+		t.Errorf("unexpected second attempt %+v", second)
+	}
+}
+
+func TestStopRestartingJob(t *testing.T) {
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command: "true",
+		Restart: runnable.RestartPolicy{
+			Mode:    runnable.RestartAlways,
+			Backoff: time.Hour,
+		},
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	job.Start()
+
+	// always restarts, even after succeeding
+	for job.Status().State != runnable.Restarting {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if next := job.Status().NextAttempt; time.Until(next) < 59*time.Minute {
+		t.Errorf("expected next attempt in an hour, got %v", next)
+	}
+
+	err := job.Stop(runnable.StopOptions{})
+	if err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+	waitForJob(t, job)
+
+	status := job.Status()
+	if status.State != runnable.Stopped || len(status.Attempts) != 1 || !status.NextAttempt.IsZero() {
+		t.Errorf("expected job to be %v after 1 attempt, got %+v", runnable.Stopped, status)
+	}
+}
+
+func TestRestartFailsToStart(t *testing.T) {
+	// a copy of true that is deleted before the job is restarted
+	command := path.Join(t.TempDir(), "true")
+	binary, err := os.ReadFile("/bin/true")
+	if err != nil {
+		t.Skipf("no /bin/true to copy %v", err)
+	}
+	if err := os.WriteFile(command, binary, 0755); err != nil {
+		t.Fatalf("failed to copy /bin/true %v", err)
+	}
+
+	job, _ := runnable.NewJob("ownerID", runnable.JobSpec{
+		Command: command,
+		Restart: runnable.RestartPolicy{
+			Mode:    runnable.RestartAlways,
+			Backoff: 500 * time.Millisecond,
+		},
+	})
+
+	lfs, _ := repository.NewLocalFileSystem("temp")
+	defer lfs.DeleteAllLogFiles()
+	logFile, _ := lfs.CreateLogFile(job.ID)
+	job.SetLogWriter(logFile)
+
+	var lock sync.Mutex
+	var states []runnable.State
+	job.SetStatusListener(func(job *runnable.Job) {
+		lock.Lock()
+		defer lock.Unlock()
+		states = append(states, job.StoredStatus().State)
+	})
+
+	job.Start()
+
+	for job.Status().State != runnable.Restarting {
+		time.Sleep(10 * time.Millisecond)
+	}
+	os.Remove(command)
+	waitForJob(t, job)
+
+	status := job.Status()
+	if status.State != runnable.Failed || status.Reason != runnable.ReasonStartFailed || status.EndTime.IsZero() {
+		t.Errorf("expected job to be %v with an end time, got %+v", runnable.Failed, status)
+	}
+	if len(status.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %v", len(status.Attempts))
+	}
+	if second := status.Attempts[1]; second.Reason != runnable.ReasonStartFailed || second.EndTime.IsZero() {
+		t.Errorf("unexpected second attempt %+v", second)
+	}
+
+	// whoever is listening, eg the job store, hears about the job failing
+	lock.Lock()
+	defer lock.Unlock()
+	if last := states[len(states)-1]; last != runnable.Failed {
+		t.Errorf("expected the last state heard to be %v, got %v", runnable.Failed, last)
+	}
+}
+
+func TestInvalidRestartPolicy(t *testing.T) {
+	for _, policy := range []runnable.RestartPolicy{
+		{Mode: "sometimes"},
+		{Mode: runnable.RestartOnFailure, MaxRetries: -1},
+		{Mode: runnable.RestartAlways, Backoff: -time.Second},
+	} {
+		_, err := runnable.NewJob("ownerID", runnable.JobSpec{Command: "true", Restart: policy})
+		if runnable.ErrorCode(err) != runnable.EINVALID {
+			t.Errorf("expected error type %v for %+v, got error %v", runnable.EINVALID, policy, err)
+		}
+	}
+}
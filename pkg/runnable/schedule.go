@@ -0,0 +1,81 @@
+package runnable
+
+import (
+	"fmt"
+	"time"
+)
+
+type OverlapPolicy string
+
+// What a schedule does when it is time to start a job, but the last one it started has not finished.
+const (
+	// Does not start a job this time.
+	OverlapSkip OverlapPolicy = "skip"
+	// Starts the job once the last one has finished. Firings that pile up while waiting start a single job.
+	OverlapQueue OverlapPolicy = "queue"
+	// Starts the job anyway.
+	OverlapAllow OverlapPolicy = "allow"
+)
+
+// Describes when a schedule starts jobs, and what they run.
+type ScheduleSpec struct {
+	// When jobs are started, eg "0 * * * *" for the start of every hour, in the server's time zone.
+	Cron string
+	// OverlapSkip if empty.
+	Overlap OverlapPolicy
+	// What every job the schedule starts runs. It can't have a deadline.
+	Job JobSpec
+}
+
+// Returns EINVALID if the overlap policy or the job are not ones a schedule can have.
+// The cron expression is left to whatever runs the schedule.
+func (spec ScheduleSpec) Validate() error {
+	op := "ScheduleSpec.Validate"
+
+	switch spec.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf("Invalid overlap policy %q, must be skip, queue or allow.", spec.Overlap),
+		}
+	}
+
+	if !spec.Job.Deadline.IsZero() {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: "Scheduled jobs can't have a deadline, give them a timeout instead.",
+		}
+	}
+
+	// checks everything else about the job the same way starting it would
+	return spec.Job.Validate()
+}
+
+// Starts a job, with the same owner, every time its cron expression matches.
+type Schedule struct {
+	ID        string
+	OwnerID   string
+	Spec      ScheduleSpec
+	Paused    bool
+	CreatedAt time.Time
+	// The groups the owner was in when they created the schedule, which its jobs are started with.
+	OwnerGroups []string
+	// The job the schedule started most recently, and when.
+	LastJobID string
+	LastRun   time.Time
+	// Why the schedule did not start a job the last time it was due to, empty if it did.
+	LastError string
+	// When the schedule is next due to start a job, zero if it is paused. Not stored.
+	NextRun time.Time
+}
+
+// The principal the schedule's jobs are started as.
+func (schedule Schedule) Owner() Principal {
+	return Principal{
+		ID:     schedule.OwnerID,
+		Groups: schedule.OwnerGroups,
+	}
+}
@@ -2,22 +2,105 @@ package runnable
 
 import (
 	"io"
+	"os"
 )
 
 type JobService interface {
-	Start(ownerID string, command string, args ...string) (string, error)
-	Stop(ownerID string, jobID string) error
-	Get(ownerID string, jobID string) (*Job, error)
-	GetLogs(ownerID string, jobID string) (*string, error)
+	// Returns the job's ID along with the error if the job was created, but could not be started.
+	Start(principal Principal, spec JobSpec) (string, error)
+	Stop(principal Principal, jobID string, opts StopOptions) error
+	// Freezes every process of a running job, until it is resumed.
+	Pause(principal Principal, jobID string) error
+	Resume(principal Principal, jobID string) error
+	Get(principal Principal, jobID string) (*Job, error)
+	// Lists the principal's jobs that match the filter in opts. The filter can ask for the jobs of another owner,
+	// or AnyOwner, if the principal is authorized to read them.
+	List(principal Principal, opts ListOptions) (JobPage, error)
+	// The returned reader must be closed, which also stops a follow early.
+	GetLogs(principal Principal, jobID string, opts LogOptions) (io.ReadCloser, error)
+	// Returns the share of running jobs every owner with running or queued jobs is entitled to, and uses.
+	// Only admins may see it.
+	Shares(principal Principal) ([]OwnerShare, error)
+}
+
+type ScheduleService interface {
+	Create(principal Principal, spec ScheduleSpec) (string, error)
+	Get(principal Principal, scheduleID string) (Schedule, error)
+	// Lists the principal's schedules, oldest first, or those of ownerID, which can be AnyOwner, if the principal
+	// is authorized to read them.
+	List(principal Principal, ownerID string) ([]Schedule, error)
+	// Jobs the schedule has already started are left alone.
+	Delete(principal Principal, scheduleID string) error
+	// Stops the schedule from starting jobs until it is resumed.
+	Pause(principal Principal, scheduleID string) error
+	Resume(principal Principal, scheduleID string) error
+}
+
+type WorkflowService interface {
+	// Starts the jobs that depend on no others straight away, and each of the others once its dependencies succeed.
+	Submit(principal Principal, spec WorkflowSpec) (string, error)
+	Get(principal Principal, workflowID string) (Workflow, error)
+	// Lists the principal's workflows, oldest first, or those of ownerID, which can be AnyOwner, if the principal
+	// is authorized to read them.
+	List(principal Principal, ownerID string) ([]Workflow, error)
+	// Stops the workflow's running jobs, and skips the ones not started yet.
+	Stop(principal Principal, workflowID string) error
+}
+
+type ScheduleStoreService interface {
+	// Adds the schedule, or replaces the one with the same ID.
+	Store(schedule Schedule) error
+	Get(scheduleID string) (Schedule, bool)
+	// Returns every schedule, in no particular order.
+	List() []Schedule
+	Delete(scheduleID string) error
 }
 
 type JobStoreService interface {
 	Store(job *Job) error
 	Get(jobID string) (*Job, bool)
+	// Returns every job that matches the filter, in no particular order.
+	List(filter JobFilter) []*Job
 }
 
 type LogFileService interface {
-	CreateLogFile(jobID string) (io.WriteCloser, error)
-	GetLogFile(jobID string) (io.ReadCloser, error)
+	CreateLogFile(jobID string) (LogWriter, error)
+	// Every reader starts from the beginning of the file.
+	GetLogFile(jobID string) (LogReader, error)
+	DeleteLogFile(jobID string) error
 	DeleteAllLogFiles() error
 }
+
+type LogWriter interface {
+	WriteRecord(record LogRecord) error
+	Close() error
+}
+
+type LogReader interface {
+	// Returns io.EOF once every record written so far has been read.
+	// Records written after that can still be read by calling it again.
+	ReadRecord() (LogRecord, error)
+	Close() error
+}
+
+type CgroupService interface {
+	// Creates a cgroup for the given job, with the limits applied.
+	Create(jobID string, limits ResourceLimits) (Cgroup, error)
+}
+
+type Cgroup interface {
+	// Opens the cgroup's directory, so that processes can be started straight into it.
+	Open() (*os.File, error)
+	// Kills every process in the cgroup.
+	Kill() error
+	// Returns whether there are no processes left in the cgroup.
+	Empty() (bool, error)
+	// Freezes every process in the cgroup, until it is thawed.
+	Freeze() error
+	Thaw() error
+	// Returns what the processes in the cgroup are using.
+	Stats() (CgroupStats, error)
+	// Returns how many processes in the cgroup have been killed for running out of memory.
+	OOMKills() (int64, error)
+	Delete() error
+}
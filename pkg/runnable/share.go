@@ -0,0 +1,14 @@
+package runnable
+
+// How much of the server an owner is entitled to, and how much they use, when jobs have to queue.
+type OwnerShare struct {
+	OwnerID string
+	// Relative to the weights of other owners, 1 by default.
+	Weight int
+	// Fraction of the running jobs the owner is entitled to, out of the owners with running or queued jobs.
+	Share float64
+	// Fraction of the running jobs that are the owner's.
+	Usage   float64
+	Running int
+	Queued  int
+}
@@ -0,0 +1,48 @@
+package runnable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Parses a signal given by name (SIGTERM or TERM) or number (15).
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if unix.SignalName(syscall.Signal(n)) == "" {
+			return 0, invalidSignal(s)
+		}
+		return syscall.Signal(n), nil
+	}
+
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, invalidSignal(s)
+	}
+
+	return sig, nil
+}
+
+func invalidSignal(s string) error {
+	return &Error{
+		Code:    EINVALID,
+		Op:      "runnable.ParseSignal",
+		Message: fmt.Sprintf("Unknown signal %q.", s),
+	}
+}
+
+// Returns the name of the signal, eg SIGTERM.
+func signalName(sig syscall.Signal) string {
+	if name := unix.SignalName(sig); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(sig))
+}
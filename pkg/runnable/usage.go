@@ -0,0 +1,41 @@
+package runnable
+
+import (
+	"syscall"
+	"time"
+)
+
+// What a finished attempt of a job used, as reported by the kernel when it was waited for.
+// It covers the job's process and every descendant that was waited for, but not those that were
+// orphaned, or killed once the job had finished.
+type ResourceUsage struct {
+	UserCPU   time.Duration
+	SystemCPU time.Duration
+	// Most memory the job's cgroup has used at once, from memory.peak. The cgroup is kept between attempts,
+	// so it covers every attempt so far. 0 if the job has no cgroup, or the kernel is older than 5.19.
+	PeakMemoryBytes int64
+	// Times a process gave up the CPU, eg to wait for IO.
+	VoluntaryContextSwitches int64
+	// Times a process was made to give up the CPU, eg because its time slice ran out.
+	InvoluntaryContextSwitches int64
+}
+
+func usageOf(rusage *syscall.Rusage) *ResourceUsage {
+	return &ResourceUsage{
+		UserCPU:                    time.Duration(rusage.Utime.Nano()),
+		SystemCPU:                  time.Duration(rusage.Stime.Nano()),
+		VoluntaryContextSwitches:   rusage.Nvcsw,
+		InvoluntaryContextSwitches: rusage.Nivcsw,
+	}
+}
+
+// What the processes in a job's cgroup are using right now, or have used so far.
+type CgroupStats struct {
+	MemoryBytes int64
+	// Most memory used at once since the cgroup was created, 0 if the kernel is older than 5.19.
+	PeakMemoryBytes int64
+	// CPU time used so far, user and system together.
+	CPUUsage     time.Duration
+	IOReadBytes  int64
+	IOWriteBytes int64
+}
@@ -0,0 +1,165 @@
+package runnable
+
+import (
+	"fmt"
+	"time"
+)
+
+type FailurePolicy string
+
+// What a workflow does when one of its jobs does not succeed.
+const (
+	// Skips the jobs that depend on the one that failed, and carries on with the rest.
+	FailureSkip FailurePolicy = "skip"
+	// Stops every running job, and skips every job that has not been started yet.
+	FailureCancel FailurePolicy = "cancel"
+)
+
+type WorkflowState string
+
+// All the possible statuses of a workflow.
+const (
+	// Some of its jobs have not finished, or not been started yet.
+	WorkflowRunning WorkflowState = "Running"
+	// Every job completed with exit code 0.
+	WorkflowSucceeded WorkflowState = "Succeeded"
+	// At least one job did not succeed, so the jobs that depend on it were skipped.
+	WorkflowFailed WorkflowState = "Failed"
+	// The workflow was stopped before it finished.
+	WorkflowStopped WorkflowState = "Stopped"
+)
+
+type NodeState string
+
+// All the possible statuses of a job in a workflow.
+const (
+	// Waiting for the jobs it depends on to succeed.
+	NodeWaiting NodeState = "Waiting"
+	// Its job has been started, and not finished yet.
+	NodeRunning NodeState = "Running"
+	// Its job completed with exit code 0.
+	NodeSucceeded NodeState = "Succeeded"
+	// Its job could not be started, exited with a non-zero code, or ended any other way.
+	NodeFailed NodeState = "Failed"
+	// It was never started, because a job it depends on did not succeed or the workflow was stopped.
+	NodeSkipped NodeState = "Skipped"
+)
+
+// A job in a workflow, and the jobs it has to wait for.
+type WorkflowNodeSpec struct {
+	// Unique within the workflow.
+	Name string
+	// Names of the jobs that have to complete successfully before this one is started.
+	DependsOn []string
+	Job       JobSpec
+}
+
+// Describes a set of jobs, and the order they run in.
+type WorkflowSpec struct {
+	Nodes []WorkflowNodeSpec
+	// FailureSkip if empty.
+	OnFailure FailurePolicy
+}
+
+// Returns EINVALID if the jobs do not form a graph without cycles, or any of them can't be started.
+func (spec WorkflowSpec) Validate() error {
+	op := "WorkflowSpec.Validate"
+	invalid := func(format string, a ...interface{}) error {
+		return &Error{
+			Code:    EINVALID,
+			Op:      op,
+			Message: fmt.Sprintf(format, a...),
+		}
+	}
+
+	switch spec.OnFailure {
+	case "", FailureSkip, FailureCancel:
+	default:
+		return invalid("Invalid failure policy %q, must be skip or cancel.", spec.OnFailure)
+	}
+
+	if len(spec.Nodes) == 0 {
+		return invalid("Workflow has no jobs.")
+	}
+
+	nodes := make(map[string]WorkflowNodeSpec)
+	for _, node := range spec.Nodes {
+		if node.Name == "" {
+			return invalid("Every job in a workflow needs a name.")
+		}
+		if _, exists := nodes[node.Name]; exists {
+			return invalid("Workflow has more than one job named %q.", node.Name)
+		}
+		nodes[node.Name] = node
+	}
+
+	for _, node := range spec.Nodes {
+		for _, parent := range node.DependsOn {
+			if _, exists := nodes[parent]; !exists {
+				return invalid("Job %q depends on %q, which is not in the workflow.", node.Name, parent)
+			}
+		}
+
+		// checks everything else about the job the same way starting it would
+		err := node.Job.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	// depth first search, a node that is reached again while still being visited is on a cycle
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visiting:
+			return invalid("Workflow has a dependency cycle through %q.", name)
+		case visited:
+			return nil
+		}
+
+		marks[name] = visiting
+		for _, parent := range nodes[name].DependsOn {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+
+	for _, node := range spec.Nodes {
+		if err := visit(node.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Where a job in a workflow is at.
+type WorkflowNode struct {
+	Name  string
+	State NodeState
+	// The job started for the node, empty until it is started.
+	JobID string
+	// Why the job could not be started, or why it was skipped.
+	Error string
+}
+
+// A set of jobs, with the same owner, each started once the jobs it depends on have succeeded.
+type Workflow struct {
+	ID        string
+	OwnerID   string
+	Spec      WorkflowSpec
+	State     WorkflowState
+	CreatedAt time.Time
+	// When the last of its jobs finished, zero while it is running.
+	EndTime time.Time
+	// In the same order as Spec.Nodes.
+	Nodes []WorkflowNode
+}
@@ -0,0 +1,43 @@
+package runnable_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+func TestWorkflowSpecValidate(t *testing.T) {
+	job := runnable.JobSpec{Command: "true"}
+
+	valid := runnable.WorkflowSpec{Nodes: []runnable.WorkflowNodeSpec{
+		{Name: "test", DependsOn: []string{"build"}, Job: job},
+		{Name: "build", Job: job},
+		{Name: "deploy", DependsOn: []string{"build", "test"}, Job: job},
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("did not expect an error, got %v", err)
+	}
+
+	for _, spec := range []runnable.WorkflowSpec{
+		{},
+		{OnFailure: "retry", Nodes: []runnable.WorkflowNodeSpec{{Name: "a", Job: job}}},
+		{Nodes: []runnable.WorkflowNodeSpec{{Job: job}}},
+		{Nodes: []runnable.WorkflowNodeSpec{{Name: "a", Job: job}, {Name: "a", Job: job}}},
+		{Nodes: []runnable.WorkflowNodeSpec{{Name: "a", DependsOn: []string{"b"}, Job: job}}},
+		{Nodes: []runnable.WorkflowNodeSpec{{Name: "a", DependsOn: []string{"a"}, Job: job}}},
+		{Nodes: []runnable.WorkflowNodeSpec{
+			{Name: "a", DependsOn: []string{"c"}, Job: job},
+			{Name: "b", DependsOn: []string{"a"}, Job: job},
+			{Name: "c", DependsOn: []string{"b"}, Job: job},
+		}},
+		{Nodes: []runnable.WorkflowNodeSpec{{Name: "a"}}},
+	} {
+		err := spec.Validate()
+
+		var rerr *runnable.Error
+		if !errors.As(err, &rerr) || rerr.Code != runnable.EINVALID {
+			t.Errorf("expected %v to be invalid, got %v", spec, err)
+		}
+	}
+}
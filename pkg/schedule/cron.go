@@ -0,0 +1,222 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+)
+
+// A parsed cron expression, which gives the times something is to be done at.
+// Each field is a bit set of the values it matches.
+type Cron struct {
+	second, minute, hour, dayOfMonth, month, dayOfWeek uint64
+	// a day matches if either day field does, unless one of them is *
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField     = cronField{min: 0, max: 59}
+	minuteField     = cronField{min: 0, max: 59}
+	hourField       = cronField{min: 0, max: 23}
+	dayOfMonthField = cronField{min: 1, max: 31}
+	monthField      = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well as 0
+	dayOfWeekField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// How far ahead Next looks, expressions that match nothing in that time never match anything.
+const cronSearchYears = 5
+
+// Parses a cron expression of the usual five fields (minute, hour, day of month, month and day of week),
+// or of six with seconds first. Fields can be *, a value, a range (1-5), a step (*/15 or 0-30/10) or a list
+// of those (1,15,30). Months and days of the week can be given by name (jan, mon). @hourly, @daily, @weekly,
+// @monthly and @yearly are understood as well.
+// Returns EINVALID if the expression can't be parsed.
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		if descriptor, ok := cronDescriptors[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(descriptor)
+		}
+	}
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, cronError(expr, "it must have 5 or 6 fields")
+	}
+
+	var cron Cron
+	for i, f := range []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{secondField, &cron.second},
+		{minuteField, &cron.minute},
+		{hourField, &cron.hour},
+		{dayOfMonthField, &cron.dayOfMonth},
+		{monthField, &cron.month},
+		{dayOfWeekField, &cron.dayOfWeek},
+	} {
+		bits, err := f.field.parse(fields[i])
+		if err != nil {
+			return nil, cronError(expr, err.Error())
+		}
+		*f.bits = bits
+	}
+
+	// Sunday is 0, whether it was given as 0 or 7
+	if cron.dayOfWeek&(1<<7) != 0 {
+		cron.dayOfWeek |= 1
+	}
+
+	cron.anyDayOfMonth = fields[3] == "*" || fields[3] == "?"
+	cron.anyDayOfWeek = fields[5] == "*" || fields[5] == "?"
+
+	if cron.Next(time.Now()).IsZero() {
+		return nil, cronError(expr, "it never matches")
+	}
+
+	return &cron, nil
+}
+
+func cronError(expr string, reason string) error {
+	return &runnable.Error{
+		Code:    runnable.EINVALID,
+		Op:      "schedule.ParseCron",
+		Message: fmt.Sprintf("Invalid cron expression %q, %v.", expr, reason),
+	}
+}
+
+// Returns the bit set of the values a field matches.
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+
+			var err error
+			start, err = f.value(bounds[0])
+			if err != nil {
+				return 0, err
+			}
+			end, err = f.value(bounds[1])
+			if err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("range %q is backwards", rangePart)
+			}
+		default:
+			var err error
+			start, err = f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+
+			// a single value only stands for itself, unless it starts a step
+			if step == 1 && !strings.Contains(part, "/") {
+				end = start
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%q is not a value between %v and %v", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Returns the first time after the given one that the expression matches, in the given time's location.
+// Returns the zero time if it matches nothing in the next few years.
+func (cron *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+
+	// moves on to the start of the next month, day, hour, minute or second, until they all match
+	for t.Before(limit) {
+		switch {
+		case !matches(cron.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !cron.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !matches(cron.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !matches(cron.minute, t.Minute()):
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		case !matches(cron.second, t.Second()):
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (cron *Cron) dayMatches(t time.Time) bool {
+	dayOfMonth := matches(cron.dayOfMonth, t.Day())
+	dayOfWeek := matches(cron.dayOfWeek, int(t.Weekday()))
+
+	if cron.anyDayOfMonth || cron.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+func matches(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
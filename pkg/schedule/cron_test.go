@@ -0,0 +1,70 @@
+package schedule_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/runnable"
+	"github.com/ambardhesi/runnable/pkg/schedule"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@often",
+		"0 0 30 feb *",
+	} {
+		_, err := schedule.ParseCron(expr)
+
+		var rerr *runnable.Error
+		if !errors.As(err, &rerr) || rerr.Code != runnable.EINVALID {
+			t.Errorf("expected %q to be invalid, got %v", expr, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a Wednesday
+	after := time.Date(2021, time.June, 2, 10, 30, 15, 0, time.UTC)
+
+	for _, test := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.June, 2, 10, 31, 0, 0, time.UTC)},
+		{"* * * * * *", time.Date(2021, time.June, 2, 10, 30, 16, 0, time.UTC)},
+		{"*/20 * * * * *", time.Date(2021, time.June, 2, 10, 30, 20, 0, time.UTC)},
+		{"0 * * * *", time.Date(2021, time.June, 2, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.June, 2, 11, 0, 0, 0, time.UTC)},
+		{"15,45 9-17 * * *", time.Date(2021, time.June, 2, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2021, time.June, 3, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2021, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		// either day field matching is enough when neither is *
+		{"0 0 15 * fri", time.Date(2021, time.June, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		cron, err := schedule.ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("did not expect an error parsing %q, got %v", test.expr, err)
+			continue
+		}
+
+		if next := cron.Next(after); !next.Equal(test.next) {
+			t.Errorf("expected %q to next match at %v, got %v", test.expr, test.next, next)
+		}
+	}
+}
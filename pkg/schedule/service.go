@@ -0,0 +1,386 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+	"github.com/google/uuid"
+)
+
+// Longest the service sleeps for before checking for due schedules again, so it notices the clock being changed.
+const maxSleep = time.Minute
+
+// implementation of runnable.ScheduleService
+// Schedules that were due while the server was down are not made up for.
+type ScheduleService struct {
+	scheduleStoreSvc runnable.ScheduleStoreService
+	jobSvc           runnable.JobService
+	authorizer       runnable.Authorizer
+	// the parsed cron expressions of every schedule
+	crons map[string]*Cron
+	// when every schedule that is not paused is next due
+	nextRuns map[string]time.Time
+	// schedules with a firing waiting for their last job to finish
+	waiting map[string]bool
+	wake    chan struct{}
+	closed  chan struct{}
+	lock    sync.Mutex
+}
+
+// Starts running the stored schedules in the background, until the service is closed.
+// authorizer can be nil, in which case users can only get at their own schedules.
+func NewScheduleService(scheduleStoreSvc runnable.ScheduleStoreService, jobSvc runnable.JobService,
+	authorizer runnable.Authorizer) (*ScheduleService, error) {
+	if authorizer == nil {
+		authorizer = auth.NewPolicyAuthorizer(auth.Policy{})
+	}
+
+	scheduleSvc := &ScheduleService{
+		scheduleStoreSvc: scheduleStoreSvc,
+		jobSvc:           jobSvc,
+		authorizer:       authorizer,
+		crons:            make(map[string]*Cron),
+		nextRuns:         make(map[string]time.Time),
+		waiting:          make(map[string]bool),
+		wake:             make(chan struct{}, 1),
+		closed:           make(chan struct{}),
+	}
+
+	now := time.Now()
+	for _, schedule := range scheduleStoreSvc.List() {
+		cron, err := ParseCron(schedule.Spec.Cron)
+		if err != nil {
+			return nil, err
+		}
+
+		scheduleSvc.crons[schedule.ID] = cron
+		if !schedule.Paused {
+			scheduleSvc.nextRuns[schedule.ID] = cron.Next(now)
+		}
+	}
+
+	go scheduleSvc.run()
+
+	return scheduleSvc, nil
+}
+
+// Stops starting jobs. Jobs that have already been started are left alone.
+func (scheduleSvc *ScheduleService) Close() {
+	close(scheduleSvc.closed)
+}
+
+func (scheduleSvc *ScheduleService) Create(principal runnable.Principal, spec runnable.ScheduleSpec) (string, error) {
+	cron, err := ParseCron(spec.Cron)
+	if err != nil {
+		return "", err
+	}
+
+	err = spec.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	// checked again every time a job is started, in case the policy has changed
+	err = scheduleSvc.authorizer.AuthorizeJob(principal, spec.Job)
+	if err != nil {
+		return "", err
+	}
+
+	if spec.Overlap == "" {
+		spec.Overlap = runnable.OverlapSkip
+	}
+
+	schedule := runnable.Schedule{
+		ID:          uuid.NewString(),
+		OwnerID:     principal.ID,
+		OwnerGroups: principal.Groups,
+		Spec:        spec,
+		CreatedAt:   time.Now(),
+	}
+
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	err = scheduleSvc.scheduleStoreSvc.Store(schedule)
+	if err != nil {
+		return "", err
+	}
+
+	scheduleSvc.crons[schedule.ID] = cron
+	scheduleSvc.nextRuns[schedule.ID] = cron.Next(time.Now())
+	scheduleSvc.wakeUp()
+
+	return schedule.ID, nil
+}
+
+func (scheduleSvc *ScheduleService) Get(principal runnable.Principal, scheduleID string) (runnable.Schedule, error) {
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	return scheduleSvc.get(principal, scheduleID, runnable.ActionRead, "ScheduleService.Get")
+}
+
+// Lists the principal's own schedules, unless ownerID is another owner's or runnable.AnyOwner.
+func (scheduleSvc *ScheduleService) List(principal runnable.Principal, ownerID string) ([]runnable.Schedule, error) {
+	if ownerID == "" {
+		ownerID = principal.ID
+	} else {
+		err := scheduleSvc.authorizer.Authorize(principal, runnable.ActionRead, ownerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	schedules := []runnable.Schedule{}
+	for _, schedule := range scheduleSvc.scheduleStoreSvc.List() {
+		if ownerID == runnable.AnyOwner || schedule.OwnerID == ownerID {
+			schedule.NextRun = scheduleSvc.nextRuns[schedule.ID]
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return schedules, nil
+}
+
+func (scheduleSvc *ScheduleService) Delete(principal runnable.Principal, scheduleID string) error {
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	_, err := scheduleSvc.get(principal, scheduleID, runnable.ActionStop, "ScheduleService.Delete")
+	if err != nil {
+		return err
+	}
+
+	err = scheduleSvc.scheduleStoreSvc.Delete(scheduleID)
+	if err != nil {
+		return err
+	}
+
+	delete(scheduleSvc.crons, scheduleID)
+	delete(scheduleSvc.nextRuns, scheduleID)
+	return nil
+}
+
+func (scheduleSvc *ScheduleService) Pause(principal runnable.Principal, scheduleID string) error {
+	return scheduleSvc.setPaused(principal, scheduleID, true, "ScheduleService.Pause")
+}
+
+func (scheduleSvc *ScheduleService) Resume(principal runnable.Principal, scheduleID string) error {
+	return scheduleSvc.setPaused(principal, scheduleID, false, "ScheduleService.Resume")
+}
+
+func (scheduleSvc *ScheduleService) setPaused(principal runnable.Principal, scheduleID string, paused bool, op string) error {
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	schedule, err := scheduleSvc.get(principal, scheduleID, runnable.ActionStop, op)
+	if err != nil {
+		return err
+	}
+
+	schedule.Paused = paused
+	schedule.NextRun = time.Time{}
+	err = scheduleSvc.scheduleStoreSvc.Store(schedule)
+	if err != nil {
+		return err
+	}
+
+	if paused {
+		delete(scheduleSvc.nextRuns, scheduleID)
+	} else if _, ok := scheduleSvc.nextRuns[scheduleID]; !ok {
+		scheduleSvc.nextRuns[scheduleID] = scheduleSvc.crons[scheduleID].Next(time.Now())
+		scheduleSvc.wakeUp()
+	}
+
+	return nil
+}
+
+// Returns the schedule, with when it next runs, if the principal may do the action to it.
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) get(principal runnable.Principal, scheduleID string, action runnable.Action, op string) (runnable.Schedule, error) {
+	schedule, exists := scheduleSvc.scheduleStoreSvc.Get(scheduleID)
+	if !exists {
+		return schedule, &runnable.Error{
+			Code:    runnable.ENOTFOUND,
+			Op:      op,
+			Message: "Schedule does not exist.",
+		}
+	}
+
+	err := scheduleSvc.authorizer.Authorize(principal, action, schedule.OwnerID)
+	if err != nil {
+		return schedule, err
+	}
+
+	schedule.NextRun = scheduleSvc.nextRuns[scheduleID]
+	return schedule, nil
+}
+
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) wakeUp() {
+	select {
+	case scheduleSvc.wake <- struct{}{}:
+	default:
+		// already due to wake up
+	}
+}
+
+// Starts the jobs of schedules as they become due, until the service is closed.
+func (scheduleSvc *ScheduleService) run() {
+	for {
+		sleep := scheduleSvc.fireDue(time.Now())
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-scheduleSvc.wake:
+		case <-scheduleSvc.closed:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// Fires every schedule that is due at the given time.
+// Returns how long until the next one is due.
+func (scheduleSvc *ScheduleService) fireDue(now time.Time) time.Duration {
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	sleep := maxSleep
+	for scheduleID, nextRun := range scheduleSvc.nextRuns {
+		if !nextRun.After(now) {
+			scheduleSvc.fire(scheduleID)
+
+			// from now, rather than from when it was due, so a late firing does not make it fire again straight away
+			nextRun = scheduleSvc.crons[scheduleID].Next(now)
+			scheduleSvc.nextRuns[scheduleID] = nextRun
+		}
+
+		if until := nextRun.Sub(now); until < sleep {
+			sleep = until
+		}
+	}
+
+	return sleep
+}
+
+// Starts the schedule's job, unless its overlap policy says otherwise.
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) fire(scheduleID string) {
+	schedule, exists := scheduleSvc.scheduleStoreSvc.Get(scheduleID)
+	if !exists {
+		return
+	}
+
+	last := scheduleSvc.lastJob(schedule)
+	if last == nil || schedule.Spec.Overlap == runnable.OverlapAllow {
+		scheduleSvc.startJob(schedule)
+		return
+	}
+
+	if schedule.Spec.Overlap == runnable.OverlapQueue {
+		if !scheduleSvc.waiting[scheduleID] {
+			scheduleSvc.waiting[scheduleID] = true
+			go scheduleSvc.startAfter(scheduleID, last)
+		}
+		return
+	}
+
+	scheduleSvc.recordError(schedule, fmt.Sprintf("Skipped, job %v was still running.", schedule.LastJobID))
+}
+
+// Returns the job the schedule started last, if it has not finished yet.
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) lastJob(schedule runnable.Schedule) *runnable.Job {
+	if schedule.LastJobID == "" {
+		return nil
+	}
+
+	job, err := scheduleSvc.jobSvc.Get(schedule.Owner(), schedule.LastJobID)
+	if err != nil {
+		return nil
+	}
+
+	select {
+	case <-job.Done():
+		return nil
+	default:
+		return job
+	}
+}
+
+// Starts the schedule's job once the last one has finished, unless the schedule is paused or deleted by then.
+func (scheduleSvc *ScheduleService) startAfter(scheduleID string, last *runnable.Job) {
+	select {
+	case <-last.Done():
+	case <-scheduleSvc.closed:
+		return
+	}
+
+	scheduleSvc.lock.Lock()
+	defer scheduleSvc.lock.Unlock()
+
+	delete(scheduleSvc.waiting, scheduleID)
+
+	schedule, exists := scheduleSvc.scheduleStoreSvc.Get(scheduleID)
+	if !exists || schedule.Paused {
+		return
+	}
+
+	scheduleSvc.startJob(schedule)
+}
+
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) startJob(schedule runnable.Schedule) {
+	spec := schedule.Spec.Job
+	spec.ScheduleID = schedule.ID
+
+	jobID, err := scheduleSvc.jobSvc.Start(schedule.Owner(), spec)
+	if err != nil {
+		// a job that was created but failed to start is still the last one the schedule started
+		if jobID != "" {
+			schedule.LastJobID = jobID
+			schedule.LastRun = time.Now()
+		}
+		scheduleSvc.recordError(schedule, fmt.Sprintf("Failed to start job %v", err))
+		return
+	}
+
+	schedule.LastJobID = jobID
+	schedule.LastRun = time.Now()
+	schedule.LastError = ""
+	scheduleSvc.store(schedule)
+}
+
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) recordError(schedule runnable.Schedule, message string) {
+	log.Printf("Schedule %v did not start a job %v\n", schedule.ID, message)
+
+	schedule.LastError = message
+	scheduleSvc.store(schedule)
+}
+
+// Must be called with the lock held.
+func (scheduleSvc *ScheduleService) store(schedule runnable.Schedule) {
+	err := scheduleSvc.scheduleStoreSvc.Store(schedule)
+	if err != nil {
+		log.Printf("Failed to record schedule %v %v\n", schedule.ID, err)
+	}
+}
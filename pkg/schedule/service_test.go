@@ -0,0 +1,198 @@
+package schedule_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/job"
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+	"github.com/ambardhesi/runnable/pkg/schedule"
+)
+
+func newScheduleService(t *testing.T) (*schedule.ScheduleService, runnable.JobService) {
+	lfs, _ := repository.NewLocalFileSystem(t.TempDir())
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{}, nil)
+
+	ss, err := schedule.NewScheduleService(repository.NewInMemoryScheduleDB(), js, nil)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	t.Cleanup(ss.Close)
+
+	return ss, js
+}
+
+// Waits for the schedule to have started at least the given number of jobs, and returns them.
+func waitForScheduledJobs(t *testing.T, js runnable.JobService, scheduleID string, count int) []*runnable.Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err := js.List(runnable.Principal{ID: "ownerID"}, runnable.ListOptions{Filter: runnable.JobFilter{ScheduleID: scheduleID}})
+		if err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+		if len(page.Jobs) >= count {
+			return page.Jobs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v jobs to be started, got %v", count, len(page.Jobs))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestScheduleStartsJobs(t *testing.T) {
+	ss, js := newScheduleService(t)
+
+	scheduleID, err := ss.Create(runnable.Principal{ID: "ownerID"}, runnable.ScheduleSpec{
+		Cron: "* * * * * *",
+		Job:  runnable.JobSpec{Command: "true"},
+	})
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	jobs := waitForScheduledJobs(t, js, scheduleID, 2)
+	for _, j := range jobs {
+		if j.OwnerID != "ownerID" || j.Spec.ScheduleID != scheduleID || j.Spec.Command != "true" {
+			t.Errorf("expected job to be started for the schedule, got %v %v", j.OwnerID, j.Spec)
+		}
+	}
+
+	s, err := ss.Get(runnable.Principal{ID: "ownerID"}, scheduleID)
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	if s.LastJobID == "" || s.LastRun.IsZero() || s.NextRun.IsZero() || s.Spec.Overlap != runnable.OverlapSkip {
+		t.Errorf("expected schedule to record its last job and when it next runs, got %v", s)
+	}
+}
+
+func TestScheduleSkipsOverlappingJobs(t *testing.T) {
+	ss, js := newScheduleService(t)
+
+	scheduleID, _ := ss.Create(runnable.Principal{ID: "ownerID"}, runnable.ScheduleSpec{
+		Cron: "* * * * * *",
+		Job:  runnable.JobSpec{Command: "sleep", Args: []string{"10"}},
+	})
+
+	jobs := waitForScheduledJobs(t, js, scheduleID, 1)
+	time.Sleep(2500 * time.Millisecond)
+
+	page, _ := js.List(runnable.Principal{ID: "ownerID"}, runnable.ListOptions{Filter: runnable.JobFilter{ScheduleID: scheduleID}})
+	if len(page.Jobs) != 1 {
+		t.Errorf("expected only 1 job to be started, got %v", len(page.Jobs))
+	}
+
+	s, _ := ss.Get(runnable.Principal{ID: "ownerID"}, scheduleID)
+	if s.LastError == "" {
+		t.Errorf("expected schedule to record that it skipped a job")
+	}
+
+	js.Stop(runnable.Principal{ID: "ownerID"}, jobs[0].ID, runnable.StopOptions{})
+}
+
+func TestPausedScheduleDoesNotStartJobs(t *testing.T) {
+	ss, js := newScheduleService(t)
+
+	scheduleID, _ := ss.Create(runnable.Principal{ID: "ownerID"}, runnable.ScheduleSpec{
+		Cron:    "* * * * * *",
+		Overlap: runnable.OverlapAllow,
+		Job:     runnable.JobSpec{Command: "true"},
+	})
+	if err := ss.Pause(runnable.Principal{ID: "ownerID"}, scheduleID); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	s, _ := ss.Get(runnable.Principal{ID: "ownerID"}, scheduleID)
+	if s.LastJobID != "" || !s.NextRun.IsZero() {
+		t.Errorf("expected paused schedule not to start jobs, got %v", s)
+	}
+
+	if err := ss.Resume(runnable.Principal{ID: "ownerID"}, scheduleID); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+	waitForScheduledJobs(t, js, scheduleID, 1)
+}
+
+func TestScheduleBelongsToOwner(t *testing.T) {
+	ss, _ := newScheduleService(t)
+
+	scheduleID, _ := ss.Create(runnable.Principal{ID: "ownerID"}, runnable.ScheduleSpec{
+		Cron: "@yearly",
+		Job:  runnable.JobSpec{Command: "true"},
+	})
+
+	for _, err := range []error{
+		func() error { _, err := ss.Get(runnable.Principal{ID: "otherID"}, scheduleID); return err }(),
+		ss.Pause(runnable.Principal{ID: "otherID"}, scheduleID),
+		ss.Delete(runnable.Principal{ID: "otherID"}, scheduleID),
+	} {
+		var rerr *runnable.Error
+		if !errors.As(err, &rerr) || rerr.Code != runnable.EUNAUTHORIZED {
+			t.Errorf("expected an unauthorized error, got %v", err)
+		}
+	}
+
+	schedules, _ := ss.List(runnable.Principal{ID: "otherID"}, "")
+	if len(schedules) != 0 {
+		t.Errorf("expected no schedules to be listed for another owner, got %v", schedules)
+	}
+
+	if err := ss.Delete(runnable.Principal{ID: "ownerID"}, scheduleID); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	_, err := ss.Get(runnable.Principal{ID: "ownerID"}, scheduleID)
+	var rerr *runnable.Error
+	if !errors.As(err, &rerr) || rerr.Code != runnable.ENOTFOUND {
+		t.Errorf("expected deleted schedule not to be found, got %v", err)
+	}
+}
+
+func TestCreateScheduleInvalid(t *testing.T) {
+	ss, _ := newScheduleService(t)
+	deadline := time.Now().Add(time.Hour)
+
+	for _, spec := range []runnable.ScheduleSpec{
+		{Cron: "not a cron", Job: runnable.JobSpec{Command: "true"}},
+		{Cron: "@daily", Overlap: "sometimes", Job: runnable.JobSpec{Command: "true"}},
+		{Cron: "@daily", Job: runnable.JobSpec{Command: "true", Deadline: deadline}},
+		{Cron: "@daily"},
+	} {
+		_, err := ss.Create(runnable.Principal{ID: "ownerID"}, spec)
+
+		var rerr *runnable.Error
+		if !errors.As(err, &rerr) || rerr.Code != runnable.EINVALID {
+			t.Errorf("expected %v to be invalid, got %v", spec, err)
+		}
+	}
+}
+
+func TestListOtherOwnersSchedules(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem(t.TempDir())
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{Users: map[string]auth.Role{"viewerID": auth.RoleViewer}})
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{}, authorizer)
+	ss, _ := schedule.NewScheduleService(repository.NewInMemoryScheduleDB(), js, authorizer)
+	t.Cleanup(ss.Close)
+
+	scheduleID, _ := ss.Create(runnable.Principal{ID: "ownerID"}, runnable.ScheduleSpec{
+		Cron: "@yearly",
+		Job:  runnable.JobSpec{Command: "true"},
+	})
+
+	for _, ownerID := range []string{"ownerID", runnable.AnyOwner} {
+		schedules, err := ss.List(runnable.Principal{ID: "viewerID"}, ownerID)
+		if err != nil || len(schedules) != 1 || schedules[0].ID != scheduleID {
+			t.Errorf("expected a viewer to list the schedules of %v, got %v %v", ownerID, schedules, err)
+		}
+	}
+
+	_, err := ss.List(runnable.Principal{ID: "otherID"}, "ownerID")
+	if runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected an unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package workflow
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+	"github.com/google/uuid"
+)
+
+// implementation of runnable.WorkflowService
+// Workflows are only kept in memory, their jobs are stored like any other.
+type WorkflowService struct {
+	jobSvc     runnable.JobService
+	workflows  map[string]*workflow
+	authorizer runnable.Authorizer
+	lock       sync.Mutex
+}
+
+type workflow struct {
+	runnable.Workflow
+	// who submitted the workflow, its jobs are started as them
+	owner runnable.Principal
+	// index of every node in Nodes and Spec.Nodes, by name
+	index map[string]int
+	// set once the workflow is stopped, or cancelled after a failure, so no more jobs are started
+	cancelled bool
+	stopped   bool
+}
+
+// authorizer can be nil, in which case users can only get at their own workflows.
+func NewWorkflowService(jobSvc runnable.JobService, authorizer runnable.Authorizer) *WorkflowService {
+	if authorizer == nil {
+		authorizer = auth.NewPolicyAuthorizer(auth.Policy{})
+	}
+
+	return &WorkflowService{
+		jobSvc:     jobSvc,
+		workflows:  make(map[string]*workflow),
+		authorizer: authorizer,
+	}
+}
+
+func (workflowSvc *WorkflowService) Submit(principal runnable.Principal, spec runnable.WorkflowSpec) (string, error) {
+	err := spec.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	for _, node := range spec.Nodes {
+		err = workflowSvc.authorizer.AuthorizeJob(principal, node.Job)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if spec.OnFailure == "" {
+		spec.OnFailure = runnable.FailureSkip
+	}
+
+	w := &workflow{
+		Workflow: runnable.Workflow{
+			ID:        uuid.NewString(),
+			OwnerID:   principal.ID,
+			Spec:      spec,
+			State:     runnable.WorkflowRunning,
+			CreatedAt: time.Now(),
+		},
+		owner: principal,
+		index: make(map[string]int),
+	}
+	for i, node := range spec.Nodes {
+		w.Nodes = append(w.Nodes, runnable.WorkflowNode{
+			Name:  node.Name,
+			State: runnable.NodeWaiting,
+		})
+		w.index[node.Name] = i
+	}
+
+	workflowSvc.lock.Lock()
+	defer workflowSvc.lock.Unlock()
+
+	workflowSvc.workflows[w.ID] = w
+	workflowSvc.advance(w)
+
+	return w.ID, nil
+}
+
+func (workflowSvc *WorkflowService) Get(principal runnable.Principal, workflowID string) (runnable.Workflow, error) {
+	workflowSvc.lock.Lock()
+	defer workflowSvc.lock.Unlock()
+
+	w, err := workflowSvc.get(principal, workflowID, runnable.ActionRead, "WorkflowService.Get")
+	if err != nil {
+		return runnable.Workflow{}, err
+	}
+
+	return w.copy(), nil
+}
+
+// Lists the principal's own workflows, unless ownerID is another owner's or runnable.AnyOwner.
+func (workflowSvc *WorkflowService) List(principal runnable.Principal, ownerID string) ([]runnable.Workflow, error) {
+	if ownerID == "" {
+		ownerID = principal.ID
+	} else {
+		err := workflowSvc.authorizer.Authorize(principal, runnable.ActionRead, ownerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	workflowSvc.lock.Lock()
+	defer workflowSvc.lock.Unlock()
+
+	workflows := []runnable.Workflow{}
+	for _, w := range workflowSvc.workflows {
+		if ownerID == runnable.AnyOwner || w.OwnerID == ownerID {
+			workflows = append(workflows, w.copy())
+		}
+	}
+
+	sort.Slice(workflows, func(i, j int) bool {
+		if !workflows[i].CreatedAt.Equal(workflows[j].CreatedAt) {
+			return workflows[i].CreatedAt.Before(workflows[j].CreatedAt)
+		}
+		return workflows[i].ID < workflows[j].ID
+	})
+
+	return workflows, nil
+}
+
+func (workflowSvc *WorkflowService) Stop(principal runnable.Principal, workflowID string) error {
+	op := "WorkflowService.Stop"
+
+	workflowSvc.lock.Lock()
+	defer workflowSvc.lock.Unlock()
+
+	w, err := workflowSvc.get(principal, workflowID, runnable.ActionStop, op)
+	if err != nil {
+		return err
+	}
+
+	if w.State != runnable.WorkflowRunning || w.cancelled {
+		return &runnable.Error{
+			Code:    runnable.EINVALID,
+			Op:      op,
+			Message: "Workflow is not running.",
+		}
+	}
+
+	w.stopped = true
+	workflowSvc.cancel(w, "Workflow was stopped.")
+	workflowSvc.finish(w)
+
+	return nil
+}
+
+// Returns the workflow, if the principal may do the action to it.
+// Must be called with the lock held.
+func (workflowSvc *WorkflowService) get(principal runnable.Principal, workflowID string, action runnable.Action, op string) (*workflow, error) {
+	w, exists := workflowSvc.workflows[workflowID]
+	if !exists {
+		return nil, &runnable.Error{
+			Code:    runnable.ENOTFOUND,
+			Op:      op,
+			Message: "Workflow does not exist.",
+		}
+	}
+
+	err := workflowSvc.authorizer.Authorize(principal, action, w.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Starts every waiting node whose dependencies have all succeeded, and skips those with a dependency
+// that did not, until there are no more of either.
+// Must be called with the lock held.
+func (workflowSvc *WorkflowService) advance(w *workflow) {
+	for changed := true; changed && !w.cancelled; {
+		changed = false
+
+		for i, spec := range w.Spec.Nodes {
+			if w.Nodes[i].State != runnable.NodeWaiting {
+				continue
+			}
+
+			ready, skip := true, ""
+			for _, parent := range spec.DependsOn {
+				switch w.Nodes[w.index[parent]].State {
+				case runnable.NodeSucceeded:
+				case runnable.NodeFailed, runnable.NodeSkipped:
+					skip = parent
+				default:
+					ready = false
+				}
+			}
+
+			if skip != "" {
+				w.Nodes[i].State = runnable.NodeSkipped
+				w.Nodes[i].Error = fmt.Sprintf("Depends on %v, which did not succeed.", skip)
+				changed = true
+			} else if ready {
+				workflowSvc.start(w, i)
+				changed = true
+			}
+
+			if w.cancelled {
+				break
+			}
+		}
+	}
+
+	workflowSvc.finish(w)
+}
+
+// Starts the node's job, and waits for it to finish in the background.
+// Must be called with the lock held.
+func (workflowSvc *WorkflowService) start(w *workflow, i int) {
+	spec := w.Spec.Nodes[i].Job
+	spec.WorkflowID = w.ID
+
+	jobID, err := workflowSvc.jobSvc.Start(w.owner, spec)
+	if err == nil {
+		var job *runnable.Job
+		job, err = workflowSvc.jobSvc.Get(w.owner, jobID)
+		if err == nil {
+			w.Nodes[i].State = runnable.NodeRunning
+			w.Nodes[i].JobID = jobID
+			go workflowSvc.watch(w, i, job)
+			return
+		}
+	}
+
+	log.Printf("Failed to start job %v of workflow %v %v\n", w.Nodes[i].Name, w.ID, err)
+	// set if the job was created, but failed to start
+	w.Nodes[i].JobID = jobID
+	w.Nodes[i].State = runnable.NodeFailed
+	w.Nodes[i].Error = fmt.Sprintf("Failed to start job %v", err)
+	workflowSvc.failed(w, i)
+}
+
+// Waits for the node's job to finish, then carries on with the rest of the workflow.
+func (workflowSvc *WorkflowService) watch(w *workflow, i int, job *runnable.Job) {
+	<-job.Done()
+
+	workflowSvc.lock.Lock()
+	defer workflowSvc.lock.Unlock()
+
+	status := job.StoredStatus()
+	switch {
+	case status.State == runnable.Completed && status.ExitCode == 0:
+		w.Nodes[i].State = runnable.NodeSucceeded
+	case status.State == runnable.Completed:
+		w.Nodes[i].State = runnable.NodeFailed
+		w.Nodes[i].Error = fmt.Sprintf("Job exited with code %v.", status.ExitCode)
+	default:
+		w.Nodes[i].State = runnable.NodeFailed
+		w.Nodes[i].Error = fmt.Sprintf("Job ended up %v.", status.State)
+	}
+
+	if w.Nodes[i].State == runnable.NodeFailed {
+		workflowSvc.failed(w, i)
+	}
+	workflowSvc.advance(w)
+}
+
+// Cancels the rest of the workflow if its failure policy says so.
+// The nodes that depend on the failed one are otherwise skipped as the workflow advances.
+// Must be called with the lock held.
+func (workflowSvc *WorkflowService) failed(w *workflow, i int) {
+	if w.Spec.OnFailure == runnable.FailureCancel && !w.cancelled {
+		workflowSvc.cancel(w, fmt.Sprintf("Workflow was cancelled, %v did not succeed.", w.Nodes[i].Name))
+	}
+}
+
+// Skips every waiting node and stops every running one.
+// Must be called with the lock held.
+func (workflowSvc *WorkflowService) cancel(w *workflow, reason string) {
+	w.cancelled = true
+
+	for i := range w.Nodes {
+		node := &w.Nodes[i]
+		switch node.State {
+		case runnable.NodeWaiting:
+			node.State = runnable.NodeSkipped
+			node.Error = reason
+		case runnable.NodeRunning:
+			// the node fails once its job has finished stopping
+			err := workflowSvc.jobSvc.Stop(w.owner, node.JobID, runnable.StopOptions{})
+			if err != nil {
+				log.Printf("Failed to stop job %v of workflow %v %v\n", node.Name, w.ID, err)
+			}
+		}
+	}
+}
+
+// Works out whether the workflow is over, once none of its nodes are waiting or running.
+// Must be called with the lock held.
+func (workflowSvc *WorkflowService) finish(w *workflow) {
+	if w.State != runnable.WorkflowRunning {
+		return
+	}
+
+	state := runnable.WorkflowSucceeded
+	for _, node := range w.Nodes {
+		switch node.State {
+		case runnable.NodeWaiting, runnable.NodeRunning:
+			return
+		case runnable.NodeFailed, runnable.NodeSkipped:
+			state = runnable.WorkflowFailed
+		}
+	}
+
+	if w.stopped {
+		state = runnable.WorkflowStopped
+	}
+
+	w.State = state
+	w.EndTime = time.Now()
+}
+
+// Returns a copy of the workflow that can be handed out while the service carries on updating it.
+func (w *workflow) copy() runnable.Workflow {
+	workflow := w.Workflow
+	workflow.Nodes = append([]runnable.WorkflowNode(nil), w.Nodes...)
+	return workflow
+}
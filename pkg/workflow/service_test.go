@@ -0,0 +1,220 @@
+package workflow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ambardhesi/runnable/pkg/auth"
+	"github.com/ambardhesi/runnable/pkg/job"
+	"github.com/ambardhesi/runnable/pkg/repository"
+	"github.com/ambardhesi/runnable/pkg/runnable"
+	"github.com/ambardhesi/runnable/pkg/workflow"
+)
+
+func newWorkflowService(t *testing.T) (*workflow.WorkflowService, runnable.JobService) {
+	lfs, _ := repository.NewLocalFileSystem(t.TempDir())
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{}, nil)
+
+	return workflow.NewWorkflowService(js, nil), js
+}
+
+func shell(script string) runnable.JobSpec {
+	return runnable.JobSpec{Command: script, Shell: true}
+}
+
+// Waits for the workflow to finish, and returns it.
+func waitForWorkflow(t *testing.T, ws *workflow.WorkflowService, workflowID string) runnable.Workflow {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w, err := ws.Get(runnable.Principal{ID: "ownerID"}, workflowID)
+		if err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+		if w.State != runnable.WorkflowRunning {
+			return w
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected workflow to finish, got %v", w)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func nodeStates(w runnable.Workflow) map[string]runnable.NodeState {
+	states := make(map[string]runnable.NodeState)
+	for _, node := range w.Nodes {
+		states[node.Name] = node.State
+	}
+	return states
+}
+
+func TestWorkflowRunsJobsAfterTheirDependencies(t *testing.T) {
+	ws, js := newWorkflowService(t)
+
+	workflowID, err := ws.Submit(runnable.Principal{ID: "ownerID"}, runnable.WorkflowSpec{Nodes: []runnable.WorkflowNodeSpec{
+		{Name: "last", DependsOn: []string{"left", "right"}, Job: shell("true")},
+		{Name: "left", DependsOn: []string{"first"}, Job: shell("sleep 0.2")},
+		{Name: "right", DependsOn: []string{"first"}, Job: shell("true")},
+		{Name: "first", Job: shell("true")},
+	}})
+	if err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	w := waitForWorkflow(t, ws, workflowID)
+	if w.State != runnable.WorkflowSucceeded || w.EndTime.IsZero() {
+		t.Errorf("expected workflow to succeed, got %v", w)
+	}
+
+	// every job starts after the jobs it depends on have ended
+	jobs := make(map[string]runnable.Status)
+	for _, node := range w.Nodes {
+		if node.State != runnable.NodeSucceeded {
+			t.Errorf("expected %v to succeed, got %v", node.Name, node)
+		}
+
+		j, err := js.Get(runnable.Principal{ID: "ownerID"}, node.JobID)
+		if err != nil {
+			t.Fatalf("did not expect an error, got %v", err)
+		}
+		if j.Spec.WorkflowID != workflowID {
+			t.Errorf("expected job to be linked to its workflow, got %v", j.Spec.WorkflowID)
+		}
+		jobs[node.Name] = j.Status()
+	}
+
+	for _, spec := range w.Spec.Nodes {
+		for _, parent := range spec.DependsOn {
+			if jobs[spec.Name].StartTime.Before(jobs[parent].EndTime) {
+				t.Errorf("expected %v to start after %v ended", spec.Name, parent)
+			}
+		}
+	}
+}
+
+func TestWorkflowSkipsJobsAfterAFailure(t *testing.T) {
+	ws, _ := newWorkflowService(t)
+
+	workflowID, _ := ws.Submit(runnable.Principal{ID: "ownerID"}, runnable.WorkflowSpec{Nodes: []runnable.WorkflowNodeSpec{
+		{Name: "fails", Job: shell("exit 3")},
+		{Name: "after", DependsOn: []string{"fails"}, Job: shell("true")},
+		{Name: "afterAfter", DependsOn: []string{"after"}, Job: shell("true")},
+		{Name: "independent", Job: shell("sleep 0.2")},
+	}})
+
+	w := waitForWorkflow(t, ws, workflowID)
+	if w.State != runnable.WorkflowFailed {
+		t.Errorf("expected workflow to fail, got %v", w.State)
+	}
+
+	expected := map[string]runnable.NodeState{
+		"fails":       runnable.NodeFailed,
+		"after":       runnable.NodeSkipped,
+		"afterAfter":  runnable.NodeSkipped,
+		"independent": runnable.NodeSucceeded,
+	}
+	for name, state := range nodeStates(w) {
+		if state != expected[name] {
+			t.Errorf("expected %v to be %v, got %v", name, expected[name], state)
+		}
+	}
+}
+
+func TestWorkflowCancelsJobsAfterAFailure(t *testing.T) {
+	ws, _ := newWorkflowService(t)
+
+	workflowID, _ := ws.Submit(runnable.Principal{ID: "ownerID"}, runnable.WorkflowSpec{
+		OnFailure: runnable.FailureCancel,
+		Nodes: []runnable.WorkflowNodeSpec{
+			{Name: "fails", Job: shell("sleep 0.2; exit 3")},
+			{Name: "after", DependsOn: []string{"fails"}, Job: shell("true")},
+			{Name: "independent", Job: shell("sleep 10")},
+		},
+	})
+
+	w := waitForWorkflow(t, ws, workflowID)
+	if w.State != runnable.WorkflowFailed {
+		t.Errorf("expected workflow to fail, got %v", w.State)
+	}
+
+	states := nodeStates(w)
+	if states["after"] != runnable.NodeSkipped || states["independent"] != runnable.NodeFailed {
+		t.Errorf("expected the rest of the workflow to be cancelled, got %v", states)
+	}
+}
+
+func TestStopWorkflow(t *testing.T) {
+	ws, _ := newWorkflowService(t)
+
+	workflowID, _ := ws.Submit(runnable.Principal{ID: "ownerID"}, runnable.WorkflowSpec{Nodes: []runnable.WorkflowNodeSpec{
+		{Name: "running", Job: shell("sleep 10")},
+		{Name: "waiting", DependsOn: []string{"running"}, Job: shell("true")},
+	}})
+
+	if err := ws.Stop(runnable.Principal{ID: "ownerID"}, workflowID); err != nil {
+		t.Fatalf("did not expect an error, got %v", err)
+	}
+
+	w := waitForWorkflow(t, ws, workflowID)
+	states := nodeStates(w)
+	if w.State != runnable.WorkflowStopped || states["running"] != runnable.NodeFailed || states["waiting"] != runnable.NodeSkipped {
+		t.Errorf("expected workflow to be stopped, got %v %v", w.State, states)
+	}
+
+	err := ws.Stop(runnable.Principal{ID: "ownerID"}, workflowID)
+	var rerr *runnable.Error
+	if !errors.As(err, &rerr) || rerr.Code != runnable.EINVALID {
+		t.Errorf("expected stopping a finished workflow to be invalid, got %v", err)
+	}
+}
+
+func TestWorkflowBelongsToOwner(t *testing.T) {
+	ws, _ := newWorkflowService(t)
+
+	workflowID, _ := ws.Submit(runnable.Principal{ID: "ownerID"}, runnable.WorkflowSpec{Nodes: []runnable.WorkflowNodeSpec{
+		{Name: "only", Job: shell("true")},
+	}})
+	waitForWorkflow(t, ws, workflowID)
+
+	for _, err := range []error{
+		func() error { _, err := ws.Get(runnable.Principal{ID: "otherID"}, workflowID); return err }(),
+		ws.Stop(runnable.Principal{ID: "otherID"}, workflowID),
+	} {
+		var rerr *runnable.Error
+		if !errors.As(err, &rerr) || rerr.Code != runnable.EUNAUTHORIZED {
+			t.Errorf("expected an unauthorized error, got %v", err)
+		}
+	}
+
+	if workflows, _ := ws.List(runnable.Principal{ID: "otherID"}, ""); len(workflows) != 0 {
+		t.Errorf("expected no workflows to be listed for another owner, got %v", workflows)
+	}
+	if workflows, _ := ws.List(runnable.Principal{ID: "ownerID"}, ""); len(workflows) != 1 {
+		t.Errorf("expected the owner's workflow to be listed, got %v", workflows)
+	}
+}
+
+func TestListOtherOwnersWorkflows(t *testing.T) {
+	lfs, _ := repository.NewLocalFileSystem(t.TempDir())
+	authorizer := auth.NewPolicyAuthorizer(auth.Policy{Users: map[string]auth.Role{"viewerID": auth.RoleViewer}})
+	js := job.NewJobService(repository.NewInMemoryDB(), lfs, nil, job.SchedulerConfig{}, authorizer)
+	ws := workflow.NewWorkflowService(js, authorizer)
+
+	workflowID, _ := ws.Submit(runnable.Principal{ID: "ownerID"}, runnable.WorkflowSpec{Nodes: []runnable.WorkflowNodeSpec{
+		{Name: "only", Job: shell("true")},
+	}})
+	waitForWorkflow(t, ws, workflowID)
+
+	for _, ownerID := range []string{"ownerID", runnable.AnyOwner} {
+		workflows, err := ws.List(runnable.Principal{ID: "viewerID"}, ownerID)
+		if err != nil || len(workflows) != 1 || workflows[0].ID != workflowID {
+			t.Errorf("expected a viewer to list the workflows of %v, got %v %v", ownerID, workflows, err)
+		}
+	}
+
+	_, err := ws.List(runnable.Principal{ID: "otherID"}, "ownerID")
+	if runnable.ErrorCode(err) != runnable.EUNAUTHORIZED {
+		t.Errorf("expected an unauthorized error, got %v", err)
+	}
+}